@@ -0,0 +1,74 @@
+package fennec
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+type (
+	// BatchResult результат сравнения запроса с одним из кандидатов MatchMany
+	BatchResult struct {
+		// индекс кандидата во входном списке
+		Index int
+		MatchResult
+	}
+)
+
+// MatchMany сравнивает query с каждым из candidates (как MatchPrepared(candidate, query), т.е. Offset -
+// позиция начала query внутри кандидата) на GOMAXPROCS горутинах. Результаты возвращаются по убыванию Score.
+// При отмене ctx или истечении его дедлайна возвращается ctx.Err(). ctx проверяется только между сравнениями:
+// уже начатые досчитываются, так что возврат задерживается на время одного сравнения.
+func (m *Matcher) MatchMany(ctx context.Context, query *PreparedHashes, candidates []*PreparedHashes) ([]BatchResult, error) {
+	results := make([]BatchResult, len(candidates))
+
+	err := parallel(ctx, len(candidates), func(idx int) {
+		results[idx] = BatchResult{Index: idx, MatchResult: m.MatchPrepared(candidates[idx], query)}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	return results, nil
+}
+
+// parallel вызывает fn для каждого индекса 0..n-1 на GOMAXPROCS горутинах.
+// ctx проверяется только между задачами: при отмене новые задачи не запускаются, а начатые не прерываются,
+// и ctx.Err() возвращается после их завершения.
+func parallel(ctx context.Context, n int, fn func(idx int)) error {
+	workers := minInt(runtime.GOMAXPROCS(0), n)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				fn(idx)
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for idx := 0; idx < n; idx++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return err
+}
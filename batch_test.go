@@ -0,0 +1,98 @@
+package fennec
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMatchMany проверяет, что параллельное сравнение дает те же результаты, что и последовательное
+// (запускать с -race: Matcher используется из нескольких горутин)
+func TestMatchMany(t *testing.T) {
+	m := NewMatcher()
+
+	var (
+		tracks     []Hashes
+		candidates []*PreparedHashes
+	)
+	for seed := int64(1); seed <= 16; seed++ {
+		hashes := testTrackHashes(60, 30, seed)
+		tracks = append(tracks, hashes)
+		candidates = append(candidates, m.Prepare(hashes))
+	}
+
+	const found = 5
+	query := testClip(tracks[found], 20, 10)
+
+	results, err := m.MatchMany(context.Background(), m.Prepare(query), candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(candidates) {
+		t.Fatalf("%d results for %d candidates", len(results), len(candidates))
+	}
+
+	if results[0].Index != found {
+		t.Errorf("best candidate %d, expected %d: %s", results[0].Index, found, results[0].MatchResult)
+	}
+
+	seen := make(map[int]bool)
+	for i, res := range results {
+		if (i > 0) && (res.Score > results[i-1].Score) {
+			t.Errorf("result %d: score %f after %f", i, res.Score, results[i-1].Score)
+		}
+		if seen[res.Index] {
+			t.Errorf("candidate %d returned twice", res.Index)
+		}
+		seen[res.Index] = true
+
+		if expected := m.Match(tracks[res.Index], query); !reflect.DeepEqual(res.MatchResult, expected) {
+			t.Errorf("candidate %d: %s, sequential %s", res.Index, res.MatchResult, expected)
+		}
+	}
+}
+
+// TestParallelCancel проверяет, что после отмены ctx новые задачи не запускаются, а начатые завершаются до возврата
+func TestParallelCancel(t *testing.T) {
+	const (
+		n        = 1000
+		cancelAt = 10
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var started, running, done int32
+	err := parallel(ctx, n, func(idx int) {
+		atomic.AddInt32(&started, 1)
+		atomic.AddInt32(&running, 1)
+		if idx == cancelAt {
+			cancel()
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&done, 1)
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v, expected %v", err, context.Canceled)
+	}
+	if started := atomic.LoadInt32(&started); (started <= cancelAt) || (started >= n) {
+		t.Errorf("%d of %d jobs started after cancel at %d", started, n, cancelAt)
+	}
+	if running := atomic.LoadInt32(&running); running != 0 {
+		t.Errorf("%d jobs still running after return", running)
+	}
+	if started, done := atomic.LoadInt32(&started), atomic.LoadInt32(&done); started != done {
+		t.Errorf("%d jobs started, %d done", started, done)
+	}
+
+	m := NewMatcher()
+	candidates := []*PreparedHashes{m.Prepare(testTrackHashes(10, 30, 1))}
+	if res, err := m.MatchMany(ctx, m.Prepare(testTrackHashes(10, 30, 2)), candidates); (res != nil) || !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled MatchMany: %d results, error %v", len(res), err)
+	}
+}
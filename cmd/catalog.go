@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// файл с именами треков в директории дискового индекса: строки "TrackID\tname"
+	namesFileName = `names.tsv`
+
+	fncFileExt = `.fnc`
+)
+
+type (
+	// fingerprintCatalog каталог, умеющий искать отпечатки с проверкой версии (Index, DiskIndex)
+	fingerprintCatalog interface {
+		fennec.Catalog
+		QueryFingerprint(fp fennec.Fingerprint, topK int) ([]fennec.QueryResult, error)
+	}
+
+	// trackNames имена треков каталога
+	trackNames map[fennec.TrackID]string
+)
+
+// loadCatalog открывает дисковый индекс indexDir (вместе с именами треков) или, если он не задан, строит индекс
+// в памяти по файлам tracks (аудио или .fnc; треки нумеруются с 1 в порядке перечисления)
+func loadCatalog(fp *fennec.Fingerprinter, indexDir string, tracks []string) (catalog fingerprintCatalog, names trackNames, closeFn func(), err error) {
+	if indexDir != `` {
+		if names, err = readNames(indexDir); err != nil {
+			return nil, nil, nil, err
+		}
+
+		di, err := fennec.OpenDiskIndex(indexDir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return di, names, func() { di.Close() }, nil
+	}
+
+	names = make(trackNames)
+	idx := fennec.NewIndex()
+	for i, p := range tracks {
+		trackFp, name, err := loadFingerprint(fp, p)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		id := fennec.TrackID(i + 1)
+		if err := idx.AddFingerprint(id, trackFp); err != nil {
+			return nil, nil, nil, fmt.Errorf(`%s: %w`, p, err)
+		}
+		names[id] = name
+	}
+
+	return idx, names, func() {}, nil
+}
+
+// loadFingerprint читает отпечаток из .fnc файла или строит его по аудио файлу.
+// Имя трека берется из метаданных .fnc (name), иначе - имя файла.
+func loadFingerprint(fp *fennec.Fingerprinter, p string) (fennec.Fingerprint, string, error) {
+	if strings.EqualFold(filepath.Ext(p), fncFileExt) {
+		f, err := fennec.ReadFncFile(p)
+		if err != nil {
+			return fennec.Fingerprint{}, ``, fmt.Errorf(`%s: %w`, p, err)
+		}
+		name := f.Meta[`name`]
+		if name == `` {
+			name = path.Base(p)
+		}
+		return f.Fingerprint(), name, nil
+	}
+
+	trackFp, err := fp.FingerprintFromFile(p)
+	if err != nil {
+		return fennec.Fingerprint{}, ``, fmt.Errorf(`%s: %w`, p, err)
+	}
+
+	return trackFp, path.Base(p), nil
+}
+
+// name возвращает имя трека или его ID, если имя неизвестно
+func (names trackNames) name(id fennec.TrackID) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return fmt.Sprintf(`#%d`, id)
+}
+
+// find ищет трек по имени или по ID в виде #123
+func (names trackNames) find(s string) (fennec.TrackID, bool) {
+	if strings.HasPrefix(s, `#`) {
+		if id, err := strconv.ParseUint(s[1:], 10, 32); err == nil {
+			_, ok := names[fennec.TrackID(id)]
+			return fennec.TrackID(id), ok
+		}
+	}
+
+	for id, name := range names {
+		if name == s {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// nextID возвращает ID, больший всех имеющихся
+func (names trackNames) nextID() fennec.TrackID {
+	var next fennec.TrackID = 1
+	for id := range names {
+		if id >= next {
+			next = id + 1
+		}
+	}
+	return next
+}
+
+// readNames читает имена треков дискового индекса (если файла нет - пустой список)
+func readNames(dir string) (trackNames, error) {
+	names := make(trackNames)
+
+	fd, err := os.Open(filepath.Join(dir, namesFileName))
+	if os.IsNotExist(err) {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == `` {
+			continue
+		}
+
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf(`%s: bad line %q`, namesFileName, line)
+		}
+		id, err := strconv.ParseUint(line[:tab], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: bad line %q`, namesFileName, line)
+		}
+		names[fennec.TrackID(id)] = line[tab+1:]
+	}
+
+	return names, sc.Err()
+}
+
+// writeNames атомарно (через временный файл) записывает имена треков дискового индекса
+func writeNames(dir string, names trackNames) (err error) {
+	ids := make([]fennec.TrackID, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		fmt.Fprintf(&buf, "%d\t%s\n", id, names[id])
+	}
+
+	fd, err := os.CreateTemp(dir, `names-*.tmp`)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			fd.Close()
+			os.Remove(fd.Name())
+		}
+	}()
+
+	if _, err = fd.Write(buf.Bytes()); err != nil {
+		return err
+	} else if err = fd.Chmod(0644); err != nil {
+		return err
+	} else if err = fd.Sync(); err != nil {
+		return err
+	} else if err = fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fd.Name(), filepath.Join(dir, namesFileName))
+}
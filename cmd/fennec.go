@@ -4,7 +4,6 @@ import (
 	"flag"
 	"fmt"
 	fennec "github.com/atercattus/fennec-tiny"
-	"math"
 	"os"
 	"path"
 )
@@ -14,6 +13,9 @@ var (
 		withSpectre bool
 		withPeaks   bool
 		withPairs   bool
+
+		format        string
+		minSimilarity float64
 	}
 )
 
@@ -21,6 +23,8 @@ func init() {
 	flag.BoolVar(&argv.withSpectre, `spectre`, false, `Write spectre PNGs (save in current directory)`)
 	flag.BoolVar(&argv.withPeaks, `peaks`, false, `Visualize peaks on spectre`)
 	flag.BoolVar(&argv.withPairs, `pairs`, false, `Visualize peaks pairs on spectre`)
+	flag.StringVar(&argv.format, `format`, formatText, `Output format: text, json or csv`)
+	flag.Float64Var(&argv.minSimilarity, `min-similarity`, defaultMinSimilarity, `Minimal similarity (0..1) treated as a match`)
 	flag.Parse()
 	if argv.withPeaks || argv.withPairs {
 		argv.withSpectre = true
@@ -28,7 +32,7 @@ func init() {
 }
 
 func loadHashes(p string) (fennec.Hashes, error) {
-	if peaks, spectre, err := fennec.GenPeaksFromMp3WithSpectre(p); err != nil {
+	if peaks, spectre, err := fennec.GenPeaksFromFileWithSpectre(p); err != nil {
 		return nil, err
 	} else {
 		hashes := fennec.FindHashes(peaks)
@@ -41,7 +45,9 @@ func loadHashes(p string) (fennec.Hashes, error) {
 			if !argv.withPeaks {
 				peaks = nil
 			}
-			fennec.SaveToPng(fennec.VisualizeSpectre(spectre, peaks, hashesDraw), path.Base(p)+`.png`)
+			if err := fennec.SaveToPng(fennec.VisualizeSpectre(spectre, peaks, hashesDraw), path.Base(p)+`.png`); err != nil {
+				return nil, err
+			}
 		}
 
 		return hashes, nil
@@ -49,47 +55,63 @@ func loadHashes(p string) (fennec.Hashes, error) {
 }
 
 func main() {
+	switch flag.Arg(0) {
+	case `tracklist`:
+		os.Exit(runTracklist(flag.Args()[1:]))
+	case `serve`:
+		os.Exit(runServe(flag.Args()[1:]))
+	case `grpc`:
+		os.Exit(runGRPC(flag.Args()[1:]))
+	case `fingerprint`:
+		os.Exit(runFingerprint(flag.Args()[1:]))
+	case `match`:
+		os.Exit(runMatch(flag.Args()[1:]))
+	case `index`:
+		os.Exit(runIndex(flag.Args()[1:]))
+	case `query`:
+		os.Exit(runQuery(flag.Args()[1:]))
+	case `inspect`:
+		os.Exit(runInspect(flag.Args()[1:]))
+	case `matrix`:
+		os.Exit(runMatrix(flag.Args()[1:]))
+	}
+
 	if len(flag.Args()) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [params] track1.mp3 track2.mp3\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [params] track1.mp3|wav|flac track2.mp3|wav|flac\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s fingerprint [params] track1.mp3|wav|flac [track2.mp3|wav|flac ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s match [params] track1.mp3|wav|flac|fnc track2.mp3|wav|flac|fnc\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s index build|add|remove -index dir ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s query [params] clip.mp3|wav|flac|fnc [track1.mp3|wav|flac|fnc ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s inspect [params] track.fnc|mp3|wav|flac [track2 ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s matrix [params] dir\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s tracklist [params] mix.mp3|wav|flac [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [params] [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s grpc [params] [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exit code: %d - match (or success), %d - no match, %d - error\n", exitMatch, exitNoMatch, exitError)
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
-	var (
-		hashes1 fennec.Hashes
-		hashes2 fennec.Hashes
-		err     error
-	)
-
-	if hashes1, err = loadHashes(flag.Arg(0)); err != nil {
-		panic(err)
-	} else if hashes2, err = loadHashes(flag.Arg(1)); err != nil {
-		panic(err)
+	if err := checkOutputFormat(argv.format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
 	}
 
-	l := len(hashes1)
-	if l2 := len(hashes2); l2 < l {
-		l = l2
+	hashes1, err := loadHashes(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
 	}
-	if l == 0 {
-		fmt.Println(`0 (no data)`)
-		return
+	hashes2, err := loadHashes(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
 	}
 
-	score, offset, _ := fennec.NewMatcher().Match(hashes1, hashes2)
-	eq := 100 * float32(score) / float32(l) // самый примитивный вариант подсчета итоговой похожести
-	eq = eq2perc(eq)
-
-	fmt.Printf("%.3f (offset %.2f sec)\n", eq, offset)
-}
-
-func eq2perc(eq float32) float32 {
-	if eq <= 0 {
-		return 0
+	res := fennec.MatchResult{LenA: len(hashes1), LenB: len(hashes2)}
+	if (res.LenA > 0) && (res.LenB > 0) {
+		res = fennec.NewMatcher().Match(hashes1, hashes2)
 	}
 
-	e := float64(eq) / 100
-	perc := math.Pow(e, 1.0/3.0) - 0.3
-	perc = math.Min(1, 1.4*math.Max(0, perc))
-	return float32(100 * perc)
+	os.Exit(reportMatch(argv.format, res, path.Base(flag.Arg(0)), path.Base(flag.Arg(1)), argv.minSimilarity))
 }
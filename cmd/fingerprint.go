@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	// metaFlags повторяемый флаг -meta key=value
+	metaFlags map[string]string
+)
+
+func (m metaFlags) String() string {
+	return fmt.Sprint(map[string]string(m))
+}
+
+func (m metaFlags) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq <= 0 {
+		return fmt.Errorf(`expected key=value, got %q`, s)
+	}
+	m[s[:eq]] = s[eq+1:]
+	return nil
+}
+
+// runFingerprint строит отпечатки и сохраняет их в .fnc: fennec fingerprint [params] track1.mp3 [track2.flac ...]
+func runFingerprint(args []string) int {
+	fs := flag.NewFlagSet(`fingerprint`, flag.ExitOnError)
+
+	outDir := fs.String(`o`, `.`, `Output directory for .fnc files`)
+	meta := make(metaFlags)
+	fs.Var(meta, `meta`, `Metadata key=value stored in every written file (repeatable)`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s fingerprint [params] track1.mp3|wav|flac [track2.mp3|wav|flac ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	fp := fennec.DefaultFingerprinter()
+
+	exitCode := 0
+	for _, p := range fs.Args() {
+		f, err := fingerprintFile(fp, p, meta)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = exitError
+			continue
+		}
+
+		base := path.Base(p)
+		out := filepath.Join(*outDir, strings.TrimSuffix(base, path.Ext(base))+fncFileExt)
+		if err := fennec.WriteFncFile(out, f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = exitError
+			continue
+		}
+
+		fmt.Printf("%s: %d hashes, %s -> %s\n", p, len(f.Hashes), fmtDuration(f.Duration), out)
+	}
+
+	return exitCode
+}
+
+// fingerprintFile строит отпечаток аудио файла вместе с заголовком .fnc. В метаданные попадают meta и имя файла (name).
+func fingerprintFile(fp *fennec.Fingerprinter, p string, meta map[string]string) (*fennec.FncFile, error) {
+	pcm, err := fp.ReadAudio(p)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, p, err)
+	}
+
+	trackFp, err := fp.Fingerprint(pcm)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, p, err)
+	}
+
+	fileMeta := map[string]string{`name`: path.Base(p)}
+	for k, v := range meta {
+		fileMeta[k] = v
+	}
+
+	sampleRate := fp.Config().SampleRate
+	return &fennec.FncFile{
+		FncHeader: fennec.FncHeader{
+			Version:    trackFp.Version,
+			SampleRate: sampleRate,
+			Duration:   time.Duration(len(pcm)) * time.Second / time.Duration(sampleRate),
+			Meta:       fileMeta,
+		},
+		Hashes: trackFp.Hashes,
+	}, nil
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"github.com/atercattus/fennec-tiny/fennecpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	// число результатов Query, если клиент его не задал
+	defaultQueryTopK = 10
+)
+
+type (
+	// grpcServer реализация fennecpb.FennecServer поверх библиотеки
+	grpcServer struct {
+		fennecpb.UnimplementedFennecServer
+
+		fp         *fennec.Fingerprinter
+		matcher    *fennec.Matcher
+		catalog    fingerprintCatalog
+		names      trackNames
+		monitorCfg fennec.MonitorConfig
+	}
+
+	// frameReader покадровый декодер аудио (MP3, WAV, FLAC, PCM)
+	frameReader interface {
+		ReadFrame(buf []int16) ([]int16, error)
+		Close() error
+	}
+)
+
+// runGRPC запускает gRPC сервис: fennec grpc [params] [track1.mp3|wav|flac ...]
+func runGRPC(args []string) int {
+	fs := flag.NewFlagSet(`grpc`, flag.ExitOnError)
+
+	addr := fs.String(`addr`, `:9090`, `Listen address`)
+	indexDir := fs.String(`index`, ``, `Serve queries from disk index in this directory instead of track files`)
+	maxMsg := fs.Int(`max-msg`, 64<<20, `Max request message size in bytes`)
+
+	monitorCfg := fennec.DefaultMonitorConfig
+	fs.DurationVar(&monitorCfg.Window, `window`, monitorCfg.Window, `StreamIdentify: length of the stream window matched against tracks`)
+	fs.DurationVar(&monitorCfg.Step, `step`, monitorCfg.Step, `StreamIdentify: step between stream windows`)
+	fs.Float64Var(&monitorCfg.MinSimilarity, `min-similarity`, monitorCfg.MinSimilarity, `StreamIdentify: minimal similarity (0..1) of a window with a track`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s grpc [params] [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *maxMsg <= 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	srv := &grpcServer{
+		fp:         fennec.DefaultFingerprinter(),
+		matcher:    fennec.NewMatcher(),
+		monitorCfg: monitorCfg,
+	}
+
+	catalog, names, closeCatalog, err := loadCatalog(srv.fp, *indexDir, fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeCatalog()
+	srv.catalog, srv.names = catalog, names
+
+	lis, err := net.Listen(`tcp`, *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	grpcSrv := grpc.NewServer(grpc.MaxRecvMsgSize(*maxMsg))
+	fennecpb.RegisterFennecServer(grpcSrv, srv)
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		healthSrv.Shutdown()
+		grpcSrv.GracefulStop()
+	}()
+
+	log.Println(`listening on`, *addr)
+	if err := grpcSrv.Serve(lis); err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	return 0
+}
+
+func (srv *grpcServer) Fingerprint(ctx context.Context, req *fennecpb.FingerprintRequest) (*fennecpb.FingerprintResponse, error) {
+	if req.GetAudio() == nil {
+		return nil, status.Error(codes.InvalidArgument, `audio is required`)
+	}
+
+	pcm, err := srv.readAudio(req.GetAudio())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	fp, err := srv.fp.Fingerprint(pcm)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &fennecpb.FingerprintResponse{
+		Fingerprint: toPbFingerprint(fp),
+		DurationSec: float64(len(pcm)) / float64(srv.fp.Config().SampleRate),
+	}, nil
+}
+
+func (srv *grpcServer) Match(ctx context.Context, req *fennecpb.MatchRequest) (*fennecpb.MatchResponse, error) {
+	a, err := fromPbFingerprint(req.GetA())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	b, err := fromPbFingerprint(req.GetB())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res, err := srv.matcher.MatchFingerprints(a, b)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &fennecpb.MatchResponse{Result: toPbMatchResult(res)}, nil
+}
+
+func (srv *grpcServer) Query(ctx context.Context, req *fennecpb.QueryRequest) (*fennecpb.QueryResponse, error) {
+	var (
+		fp  fennec.Fingerprint
+		err error
+	)
+
+	switch q := req.GetQuery().(type) {
+	case *fennecpb.QueryRequest_Fingerprint:
+		fp, err = fromPbFingerprint(q.Fingerprint)
+	case *fennecpb.QueryRequest_Audio:
+		var pcm []fennec.Float
+		if pcm, err = srv.readAudio(q.Audio); err == nil {
+			fp, err = srv.fp.Fingerprint(pcm)
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, `fingerprint or audio is required`)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	topK := int(req.GetTopK())
+	if topK <= 0 {
+		topK = defaultQueryTopK
+	}
+
+	results, err := srv.catalog.QueryFingerprint(fp, topK)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &fennecpb.QueryResponse{Results: make([]*fennecpb.QueryResult, len(results))}
+	for i, r := range results {
+		resp.Results[i] = &fennecpb.QueryResult{
+			TrackId: uint32(r.TrackID),
+			Name:    srv.names[r.TrackID],
+			Match:   toPbMatchResult(r.MatchResult),
+		}
+	}
+
+	return resp, nil
+}
+
+// StreamIdentify декодирует поток в отдельной горутине (через io.Pipe), так что чанки могут резаться
+// в любом месте, и отправляет события Monitor по мере их появления
+func (srv *grpcServer) StreamIdentify(stream fennecpb.Fennec_StreamIdentifyServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	mon, err := fennec.NewMonitor(srv.catalog, srv.fp, srv.monitorCfg)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	pr, pw := io.Pipe()
+	identifyErr := make(chan error, 1)
+	go func() {
+		err := srv.identify(stream, mon, pr, req.GetFormat(), req.GetPcm())
+		// если декодер остановился раньше конца потока, разблокируем запись чанков
+		pr.CloseWithError(errors.New(`stream decoding stopped`))
+		identifyErr <- err
+	}()
+
+	var recvErr error
+	for {
+		if len(req.GetChunk()) > 0 {
+			if _, err := pw.Write(req.GetChunk()); err != nil {
+				break
+			}
+		}
+		if req, recvErr = stream.Recv(); recvErr != nil {
+			break
+		}
+	}
+
+	if (recvErr != nil) && (recvErr != io.EOF) {
+		pw.CloseWithError(recvErr)
+		<-identifyErr
+		return recvErr
+	}
+
+	pw.Close()
+	if err := <-identifyErr; err != nil {
+		return grpcError(err)
+	}
+
+	return nil
+}
+
+// identify читает поток из r и отправляет клиенту события монитора
+func (srv *grpcServer) identify(stream fennecpb.Fennec_StreamIdentifyServer, mon *fennec.Monitor, r io.Reader, format fennecpb.AudioFormat, pcmFormat *fennecpb.PcmFormat) error {
+	rd, err := newFrameReader(r, format, pcmFormat, srv.fp.Config().SampleRate)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	// монитору передаются куски примерно по секунде, а не по фрейму декодера
+	chunkLen := srv.fp.Config().SampleRate
+
+	var (
+		frame []int16
+		pcm   []fennec.Float
+	)
+	for {
+		frame, err = rd.ReadFrame(frame)
+		if err == io.EOF {
+			frame = frame[:0]
+		} else if err != nil {
+			return err
+		}
+
+		for _, smpl := range frame {
+			pcm = append(pcm, fennec.Float(smpl)/(1<<15))
+		}
+
+		if (len(pcm) >= chunkLen) || ((err == io.EOF) && (len(pcm) > 0)) {
+			events, pushErr := mon.Push(pcm)
+			if pushErr != nil {
+				return pushErr
+			}
+			if err := srv.sendEvents(stream, events); err != nil {
+				return err
+			}
+			pcm = pcm[:0]
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	events, err := mon.Flush()
+	if err != nil {
+		return err
+	}
+
+	return srv.sendEvents(stream, events)
+}
+
+func (srv *grpcServer) sendEvents(stream fennecpb.Fennec_StreamIdentifyServer, events []fennec.MonitorEvent) error {
+	for _, ev := range events {
+		typ := fennecpb.StreamIdentifyResponse_TYPE_START
+		if ev.Type == fennec.MonitorStop {
+			typ = fennecpb.StreamIdentifyResponse_TYPE_STOP
+		}
+
+		err := stream.Send(&fennecpb.StreamIdentifyResponse{
+			Type:           typ,
+			TrackId:        uint32(ev.TrackID),
+			Name:           srv.names[ev.TrackID],
+			StreamStartSec: ev.StreamStart.Seconds(),
+			StreamEndSec:   ev.StreamEnd.Seconds(),
+			TrackOffsetSec: ev.TrackOffset.Seconds(),
+			Confidence:     ev.Confidence,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readAudio декодирует аудио целиком
+func (srv *grpcServer) readAudio(audio *fennecpb.Audio) ([]fennec.Float, error) {
+	r := bytes.NewReader(audio.GetData())
+
+	if audio.GetFormat() == fennecpb.AudioFormat_AUDIO_FORMAT_PCM {
+		return fennec.ReadRawPCM(r, fromPbPcmFormat(audio.GetPcm()))
+	}
+
+	return srv.fp.ReadAudioFrom(r, audioFormatExt(audio.GetFormat()))
+}
+
+// newFrameReader создает покадровый декодер потока r формата format, приводящий его к моно sampleRate
+func newFrameReader(r io.Reader, format fennecpb.AudioFormat, pcmFormat *fennecpb.PcmFormat, sampleRate int) (frameReader, error) {
+	switch format {
+	case fennecpb.AudioFormat_AUDIO_FORMAT_PCM:
+		rd, err := fennec.NewRawPCMReader(r, fromPbPcmFormat(pcmFormat), sampleRate, 16)
+		if err != nil {
+			return nil, err
+		}
+		return rd, nil
+	case fennecpb.AudioFormat_AUDIO_FORMAT_WAV:
+		rd, err := fennec.NewWAVReaderFrom(r, sampleRate, 16)
+		if err != nil {
+			return nil, err
+		}
+		return rd, nil
+	case fennecpb.AudioFormat_AUDIO_FORMAT_FLAC:
+		rd, err := fennec.NewFLACReaderFrom(r, sampleRate, 16)
+		if err != nil {
+			return nil, err
+		}
+		return rd, nil
+	case fennecpb.AudioFormat_AUDIO_FORMAT_UNSPECIFIED, fennecpb.AudioFormat_AUDIO_FORMAT_MP3:
+		rd, err := fennec.NewMP3ReaderFrom(r, sampleRate, 16)
+		if err != nil {
+			return nil, err
+		}
+		return rd, nil
+	default:
+		return nil, fennec.ErrUnsupportedFormat
+	}
+}
+
+// audioFormatExt переводит формат в расширение для Fingerprinter.ReadAudioFrom
+func audioFormatExt(format fennecpb.AudioFormat) string {
+	switch format {
+	case fennecpb.AudioFormat_AUDIO_FORMAT_WAV:
+		return `wav`
+	case fennecpb.AudioFormat_AUDIO_FORMAT_FLAC:
+		return `flac`
+	case fennecpb.AudioFormat_AUDIO_FORMAT_UNSPECIFIED, fennecpb.AudioFormat_AUDIO_FORMAT_MP3:
+		return `mp3`
+	default:
+		// неизвестное значение отвергается ReadAudioFrom
+		return format.String()
+	}
+}
+
+func fromPbPcmFormat(f *fennecpb.PcmFormat) fennec.RawPCMFormat {
+	return fennec.RawPCMFormat{
+		SampleRate:    int(f.GetSampleRate()),
+		Channels:      int(f.GetChannels()),
+		BitsPerSample: int(f.GetBitsPerSample()),
+		Float:         f.GetFloat(),
+	}
+}
+
+func toPbFingerprint(fp fennec.Fingerprint) *fennecpb.Fingerprint {
+	res := &fennecpb.Fingerprint{
+		Version: uint32(fp.Version),
+		Times:   make([]uint32, len(fp.Hashes)),
+		Hashes:  make([]uint32, len(fp.Hashes)),
+	}
+	for i, h := range fp.Hashes {
+		res.Times[i], res.Hashes[i] = h.Time, h.Hash
+	}
+	return res
+}
+
+func fromPbFingerprint(fp *fennecpb.Fingerprint) (fennec.Fingerprint, error) {
+	if fp == nil {
+		return fennec.Fingerprint{}, status.Error(codes.InvalidArgument, `fingerprint is required`)
+	} else if len(fp.GetTimes()) != len(fp.GetHashes()) {
+		return fennec.Fingerprint{}, status.Error(codes.InvalidArgument, `fingerprint times and hashes lengths differ`)
+	}
+
+	res := fennec.Fingerprint{
+		Version: fennec.FingerprintVersion(fp.GetVersion()),
+		Hashes:  make(fennec.Hashes, len(fp.GetHashes())),
+	}
+	for i := range res.Hashes {
+		res.Hashes[i] = fennec.Hash{Time: fp.Times[i], Hash: fp.Hashes[i]}
+	}
+	return res, nil
+}
+
+func toPbTimeSpan(s fennec.TimeSpan) *fennecpb.TimeSpan {
+	return &fennecpb.TimeSpan{Start: s.Start, End: s.End}
+}
+
+func toPbMatchResult(res fennec.MatchResult) *fennecpb.MatchResult {
+	density := make([]int32, len(res.Density))
+	for i, d := range res.Density {
+		density[i] = int32(d)
+	}
+
+	return &fennecpb.MatchResult{
+		Score:         res.Score,
+		Similarity:    res.Similarity,
+		Offset:        int32(res.Offset),
+		OffsetSec:     res.OffsetInSec,
+		Scale:         res.Scale,
+		CntInOffset:   int32(res.CntInOffset),
+		MatchedHashes: int32(res.MatchedHashes),
+		LenA:          int32(res.LenA),
+		LenB:          int32(res.LenB),
+		RegionA:       toPbTimeSpan(res.RegionA),
+		RegionB:       toPbTimeSpan(res.RegionB),
+		Density:       density,
+	}
+}
+
+// grpcError переводит ошибку библиотеки в gRPC статус
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, fennec.ErrVersionMismatch), errors.Is(err, fennec.ErrTooShort), errors.Is(err, fennec.ErrSilentInput):
+		code = codes.FailedPrecondition
+	case errors.Is(err, fennec.ErrDecode), errors.Is(err, fennec.ErrBadFnc), errors.Is(err, fennec.ErrWrongParams),
+		errors.Is(err, fennec.ErrUnsupportedFormat), errors.Is(err, fennec.ErrUnsupportedSampleRate),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		code = codes.InvalidArgument
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+
+	return status.Error(code, err.Error())
+}
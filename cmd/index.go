@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+)
+
+// runIndex ведет каталог треков в директории дискового индекса:
+//
+//	fennec index build -index dir track1.mp3 [track2.fnc ...]  новый каталог
+//	fennec index add -index dir track1.mp3 [track2.fnc ...]    добавление треков
+//	fennec index remove -index dir name1|#id1 [name2|#id2 ...] удаление треков
+func runIndex(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s index build|add -index dir track1.mp3|wav|flac|fnc [track2.mp3|wav|flac|fnc ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s index remove -index dir name1|#id1 [name2|#id2 ...]\n", os.Args[0])
+	}
+
+	if len(args) < 1 {
+		usage()
+		return exitError
+	}
+	cmd := args[0]
+
+	fs := flag.NewFlagSet(`index `+cmd, flag.ExitOnError)
+	indexDir := fs.String(`index`, ``, `Disk index directory`)
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	if (*indexDir == ``) || (fs.NArg() < 1) {
+		fs.Usage()
+		return exitError
+	}
+
+	var run func(di *fennec.DiskIndex, names trackNames, args []string) error
+	switch cmd {
+	case `build`:
+		run = indexBuild
+	case `add`:
+		run = indexAdd
+	case `remove`:
+		run = indexRemove
+	default:
+		fs.Usage()
+		return exitError
+	}
+
+	names, err := readNames(*indexDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	di, err := fennec.OpenDiskIndex(*indexDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	err = run(di, names, fs.Args())
+	if err == nil {
+		err = di.Merge()
+	}
+	if errClose := di.Close(); err == nil {
+		err = errClose
+	}
+	// имена пишутся и после ошибки: уже добавленные треки не должны остаться без имени (и их ID - заняться повторно)
+	if errNames := writeNames(*indexDir, names); err == nil {
+		err = errNames
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	return 0
+}
+
+// indexBuild наполняет пустой каталог
+func indexBuild(di *fennec.DiskIndex, names trackNames, tracks []string) error {
+	if (len(names) > 0) || (di.SegmentsCnt() > 0) {
+		return fmt.Errorf(`index already exists, use "index add"`)
+	}
+	return indexAdd(di, names, tracks)
+}
+
+// indexAdd добавляет в каталог треки с еще не занятыми именами
+func indexAdd(di *fennec.DiskIndex, names trackNames, tracks []string) error {
+	fp := fennec.DefaultFingerprinter()
+
+	for _, p := range tracks {
+		trackFp, name, err := loadFingerprint(fp, p)
+		if err != nil {
+			return err
+		}
+
+		if id, ok := names.find(name); ok {
+			return fmt.Errorf(`%s: track %q already in index as #%d`, p, name, id)
+		}
+
+		// имена пишутся после сброса индекса, так что при падении между ними в индексе могут остаться треки без имени:
+		//   их ID нельзя выдавать заново
+		id := di.MaxTrackID() + 1
+		if next := names.nextID(); next > id {
+			id = next
+		}
+		if err := di.AddFingerprint(id, trackFp); err != nil {
+			return fmt.Errorf(`%s: %w`, p, err)
+		}
+		names[id] = name
+
+		fmt.Printf("#%d %s: %d hashes\n", id, name, len(trackFp.Hashes))
+	}
+
+	return nil
+}
+
+// indexRemove удаляет треки из каталога по имени или ID (#123)
+func indexRemove(di *fennec.DiskIndex, names trackNames, tracks []string) error {
+	for _, s := range tracks {
+		id, ok := names.find(s)
+		if !ok {
+			return fmt.Errorf(`track %q not found in index`, s)
+		}
+
+		if err := di.Remove(id); err != nil {
+			return err
+		}
+		fmt.Printf("#%d %s: removed\n", id, names.name(id))
+		delete(names, id)
+	}
+
+	return nil
+}
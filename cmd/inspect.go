@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// ширина самого длинного столбца гистограммы
+	histogramWidth = 50
+)
+
+// runInspect выводит заголовок отпечатка, число хешей и их распределение по времени: fennec inspect track.fnc|mp3
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet(`inspect`, flag.ExitOnError)
+
+	buckets := fs.Int(`buckets`, 20, `Number of hash histogram buckets`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s inspect [params] track.fnc|mp3|wav|flac [track2 ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if (*buckets <= 0) || (fs.NArg() < 1) {
+		fs.Usage()
+		return exitError
+	}
+
+	fp := fennec.DefaultFingerprinter()
+
+	exitCode := 0
+	for i, p := range fs.Args() {
+		var (
+			f   *fennec.FncFile
+			err error
+		)
+		if strings.EqualFold(path.Ext(p), fncFileExt) {
+			f, err = fennec.ReadFncFile(p)
+		} else {
+			f, err = fingerprintFile(fp, p, nil)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = exitError
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		printFnc(p, f, fp, *buckets)
+	}
+
+	return exitCode
+}
+
+// printFnc выводит описание отпечатка. Время хешей переводится в секунды, только если версия отпечатка совпадает
+// с версией fp (иначе неизвестна длительность столбца спектра) - тогда гистограмма строится по столбцам.
+func printFnc(p string, f *fennec.FncFile, fp *fennec.Fingerprinter, buckets int) {
+	fmt.Printf("%s\n", p)
+	fmt.Printf("  version:     %s\n", f.Version)
+	fmt.Printf("  sample rate: %d\n", f.SampleRate)
+	fmt.Printf("  duration:    %s (%.2f sec)\n", fmtDuration(f.Duration), f.Duration.Seconds())
+
+	keys := make([]string, 0, len(f.Meta))
+	for k := range f.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  meta %s: %s\n", k, f.Meta[k])
+	}
+
+	fmt.Printf("  hashes:      %d", len(f.Hashes))
+	if sec := f.Duration.Seconds(); sec > 0 {
+		fmt.Printf(" (%.1f per sec)", float64(len(f.Hashes))/sec)
+	}
+	fmt.Println()
+
+	if len(f.Hashes) == 0 {
+		return
+	}
+
+	// хеши в .fnc отсортированы по времени
+	maxTime := f.Hashes[len(f.Hashes)-1].Time
+
+	colsInSec, unit := 1.0, `col`
+	if f.Version == fp.Version() {
+		colsInSec, unit = fp.Config().HashColsInOneSec(), `sec`
+	}
+
+	if uint32(buckets) > maxTime+1 {
+		buckets = int(maxTime + 1)
+	}
+	bucketCols := float64(maxTime+1) / float64(buckets)
+
+	counts := make([]int, buckets)
+	maxCnt := 0
+	for _, h := range f.Hashes {
+		b := minInt(int(float64(h.Time)/bucketCols), buckets-1)
+		counts[b]++
+		if counts[b] > maxCnt {
+			maxCnt = counts[b]
+		}
+	}
+
+	fmt.Printf("  hashes by time (%s):\n", unit)
+	for b, cnt := range counts {
+		from, to := float64(b)*bucketCols/colsInSec, float64(b+1)*bucketCols/colsInSec
+		bar := strings.Repeat(`#`, (cnt*histogramWidth+maxCnt-1)/maxCnt)
+		fmt.Printf("  %8.1f - %8.1f %7d %s\n", from, to, cnt, bar)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
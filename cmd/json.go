@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"strconv"
+)
+
+type (
+	// fingerprintJSON отпечаток в JSON: версия в hex (как FingerprintVersion.String) и пары [time, hash]
+	fingerprintJSON struct {
+		Version string      `json:"version"`
+		Hashes  [][2]uint32 `json:"hashes"`
+	}
+
+	spanJSON struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	}
+
+	// matchJSON результат сравнения двух треков (fennec.MatchResult)
+	matchJSON struct {
+		Score           float64  `json:"score"`
+		Similarity      float64  `json:"similarity"`
+		Offset          int      `json:"offset"`
+		OffsetInSec     float64  `json:"offset_sec"`
+		Scale           float64  `json:"scale"`
+		CntInOffset     int      `json:"cnt_in_offset"`
+		CntInOffsetPerc float64  `json:"cnt_in_offset_perc"`
+		SumOffs         int      `json:"sum_offs"`
+		CntOffs         int      `json:"cnt_offs"`
+		MatchedHashes   int      `json:"matched_hashes"`
+		LenA            int      `json:"len_a"`
+		LenB            int      `json:"len_b"`
+		ScoreK          float64  `json:"score_k"`
+		RegionA         spanJSON `json:"region_a"`
+		RegionB         spanJSON `json:"region_b"`
+		Density         []int    `json:"density,omitempty"`
+	}
+
+	// queryResultJSON трек каталога, найденный по фрагменту (fennec.QueryResult)
+	queryResultJSON struct {
+		TrackID fennec.TrackID `json:"track_id"`
+		Name    string         `json:"name,omitempty"`
+		matchJSON
+	}
+)
+
+func newFingerprintJSON(fp fennec.Fingerprint) fingerprintJSON {
+	res := fingerprintJSON{
+		Version: fp.Version.String(),
+		Hashes:  make([][2]uint32, len(fp.Hashes)),
+	}
+	for i, h := range fp.Hashes {
+		res.Hashes[i] = [2]uint32{h.Time, h.Hash}
+	}
+	return res
+}
+
+// fingerprint разбирает отпечаток обратно
+func (fj fingerprintJSON) fingerprint() (fennec.Fingerprint, error) {
+	version, err := strconv.ParseUint(fj.Version, 16, 32)
+	if err != nil {
+		return fennec.Fingerprint{}, fmt.Errorf(`bad fingerprint version %q`, fj.Version)
+	}
+
+	fp := fennec.Fingerprint{
+		Version: fennec.FingerprintVersion(version),
+		Hashes:  make(fennec.Hashes, len(fj.Hashes)),
+	}
+	for i, h := range fj.Hashes {
+		fp.Hashes[i] = fennec.Hash{Time: h[0], Hash: h[1]}
+	}
+	return fp, nil
+}
+
+func newSpanJSON(s fennec.TimeSpan) spanJSON {
+	return spanJSON{Start: s.Start, End: s.End}
+}
+
+func newMatchJSON(res fennec.MatchResult) matchJSON {
+	return matchJSON{
+		Score:           res.Score,
+		Similarity:      res.Similarity,
+		Offset:          res.Offset,
+		OffsetInSec:     res.OffsetInSec,
+		Scale:           res.Scale,
+		CntInOffset:     res.CntInOffset,
+		CntInOffsetPerc: res.CntInOffsetPerc,
+		SumOffs:         res.SumOffs,
+		CntOffs:         res.CntOffs,
+		MatchedHashes:   res.MatchedHashes,
+		LenA:            res.LenA,
+		LenB:            res.LenB,
+		ScoreK:          res.ScoreK,
+		RegionA:         newSpanJSON(res.RegionA),
+		RegionB:         newSpanJSON(res.RegionB),
+		Density:         res.Density,
+	}
+}
+
+func newQueryResultsJSON(results []fennec.QueryResult, names trackNames) []queryResultJSON {
+	res := make([]queryResultJSON, len(results))
+	for i, r := range results {
+		res[i] = queryResultJSON{
+			TrackID:   r.TrackID,
+			Name:      names[r.TrackID],
+			matchJSON: newMatchJSON(r.MatchResult),
+		}
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+)
+
+// runMatch сравнивает два трека (аудио или .fnc): fennec match [params] a.mp3|fnc b.mp3|fnc.
+// Код выхода: exitMatch, exitNoMatch или exitError.
+func runMatch(args []string) int {
+	fs := flag.NewFlagSet(`match`, flag.ExitOnError)
+
+	format := fs.String(`format`, formatText, `Output format: text, json or csv`)
+	minSimilarity := fs.Float64(`min-similarity`, defaultMinSimilarity, `Minimal similarity (0..1) treated as a match`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s match [params] track1.mp3|wav|flac|fnc track2.mp3|wav|flac|fnc\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exit code: %d - match, %d - no match, %d - error\n", exitMatch, exitNoMatch, exitError)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	if err := checkOutputFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	fp := fennec.DefaultFingerprinter()
+
+	a, nameA, err := loadFingerprint(fp, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	b, nameB, err := loadFingerprint(fp, fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	res := fennec.MatchResult{LenA: len(a.Hashes), LenB: len(b.Hashes)}
+	if (res.LenA > 0) && (res.LenB > 0) {
+		if res, err = fennec.NewMatcher().MatchFingerprints(a, b); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	return reportMatch(*format, res, nameA, nameB, *minSimilarity)
+}
+
+// reportMatch выводит результат сравнения и возвращает соответствующий код выхода
+func reportMatch(format string, res fennec.MatchResult, nameA, nameB string, minSimilarity float64) int {
+	match := isMatch(res, minSimilarity)
+
+	if err := writeMatch(os.Stdout, format, res, nameA, nameB, match); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if !match {
+		return exitNoMatch
+	}
+	return exitMatch
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// matrixJSON матрица похожести для -format json
+	matrixJSON struct {
+		Tracks     []matrixTrackJSON `json:"tracks"`
+		Similarity [][]float64       `json:"similarity"`
+		Pairs      []matrixPairJSON  `json:"pairs"`
+	}
+
+	matrixTrackJSON struct {
+		Name  string `json:"name"`
+		Error string `json:"error,omitempty"`
+	}
+
+	// matrixPairJSON сравнение пары треков (индексы в tracks)
+	matrixPairJSON struct {
+		A int `json:"a"`
+		B int `json:"b"`
+		matchJSON
+	}
+)
+
+// runMatrix строит матрицу попарной похожести аудио файлов директории: fennec matrix [params] dir
+func runMatrix(args []string) int {
+	fs := flag.NewFlagSet(`matrix`, flag.ExitOnError)
+
+	format := fs.String(`format`, formatCSV, `Output format: csv or json`)
+	out := fs.String(`o`, ``, `Write matrix to this file instead of stdout`)
+	heatmap := fs.String(`png`, ``, `Write heatmap PNG to this file`)
+	cellSize := fs.Int(`cell`, 16, `Heatmap cell size in pixels`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s matrix [params] dir\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if (fs.NArg() != 1) || (*cellSize <= 0) || ((*format != formatCSV) && (*format != formatJSON)) {
+		fs.Usage()
+		return exitError
+	}
+
+	paths, err := audioFiles(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	} else if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: no audio files\n", fs.Arg(0))
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sm, err := fennec.SimilarityMatrixFromFiles(ctx, fennec.DefaultFingerprinter(), fennec.NewMatcher(), paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	exitCode := 0
+	for i, err := range sm.Errors {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", paths[i], err)
+			exitCode = exitError
+		}
+	}
+
+	if err := writeMatrix(*out, *format, sm); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if *heatmap != `` {
+		if err := fennec.SaveToPng(fennec.VisualizeMatrix(sm.Similarity, *cellSize), *heatmap); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	return exitCode
+}
+
+// writeMatrix пишет матрицу в формате format в файл out (или в stdout, если он не задан)
+func writeMatrix(out string, format string, sm *fennec.SimilarityMatrix) (err error) {
+	w := io.Writer(os.Stdout)
+	if out != `` {
+		fd, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if errClose := fd.Close(); err == nil {
+				err = errClose
+			}
+		}()
+		w = fd
+	}
+
+	if format == formatJSON {
+		return printJSON(w, newMatrixJSON(sm))
+	}
+	return writeMatrixCSV(w, sm)
+}
+
+// audioFiles возвращает отсортированный список аудио файлов (mp3, wav, flac) директории dir
+func audioFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(path.Ext(e.Name())) {
+		case `.mp3`, `.wav`, `.wave`, `.flac`:
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// writeMatrixCSV пишет матрицу похожести: первая строка и первая колонка - имена треков
+func writeMatrixCSV(w io.Writer, sm *fennec.SimilarityMatrix) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{``}
+	for _, p := range sm.Paths {
+		header = append(header, path.Base(p))
+	}
+	cw.Write(header)
+
+	for i, row := range sm.Similarity {
+		rec := []string{path.Base(sm.Paths[i])}
+		for _, v := range row {
+			rec = append(rec, strconv.FormatFloat(v, 'f', 4, 64))
+		}
+		cw.Write(rec)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func newMatrixJSON(sm *fennec.SimilarityMatrix) matrixJSON {
+	res := matrixJSON{
+		Tracks:     make([]matrixTrackJSON, len(sm.Paths)),
+		Similarity: sm.Similarity,
+		Pairs:      make([]matrixPairJSON, len(sm.Pairs)),
+	}
+	for i, p := range sm.Paths {
+		res.Tracks[i].Name = path.Base(p)
+		if sm.Errors[i] != nil {
+			res.Tracks[i].Error = sm.Errors[i].Error()
+		}
+	}
+	for i, pair := range sm.Pairs {
+		res.Pairs[i] = matrixPairJSON{A: pair.A, B: pair.B, matchJSON: newMatchJSON(pair.MatchResult)}
+	}
+	return res
+}
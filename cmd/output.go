@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"io"
+	"strconv"
+)
+
+// Коды выхода команд сравнения (как у grep): совпадение найдено, не найдено, ошибка
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// Форматы вывода результатов сравнения (-format)
+const (
+	formatText = `text`
+	formatJSON = `json`
+	formatCSV  = `csv`
+)
+
+type (
+	// matchOutputJSON результат сравнения двух треков для -format json
+	matchOutputJSON struct {
+		A     string `json:"a"`
+		B     string `json:"b"`
+		Match bool   `json:"match"`
+		matchJSON
+	}
+
+	// queryOutputJSON результат поиска фрагмента для -format json
+	queryOutputJSON struct {
+		Clip    string            `json:"clip"`
+		Results []queryResultJSON `json:"results"`
+	}
+)
+
+var (
+	// defaultMinSimilarity порог -min-similarity по умолчанию - тот же, что откалиброван для мониторинга эфира.
+	// Небольшое ненулевое Similarity случайно набирает почти любая пара треков, поэтому совпадением оно не считается.
+	defaultMinSimilarity = fennec.DefaultMonitorConfig.MinSimilarity
+
+	// колонки CSV с результатом сравнения (Density в CSV не выводится)
+	matchCSVHeader = []string{
+		`similarity`, `score`, `offset`, `offset_sec`, `scale`,
+		`cnt_in_offset`, `cnt_in_offset_perc`, `sum_offs`, `cnt_offs`, `matched_hashes`, `len_a`, `len_b`, `score_k`,
+		`region_a_start`, `region_a_end`, `region_b_start`, `region_b_end`,
+	}
+)
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatCSV:
+		return nil
+	default:
+		return fmt.Errorf(`unknown output format %q (expected text, json or csv)`, format)
+	}
+}
+
+// isMatch решает, считать ли результат сравнения совпадением
+func isMatch(res fennec.MatchResult, minSimilarity float64) bool {
+	return (res.Similarity > 0) && (res.Similarity >= minSimilarity)
+}
+
+// writeMatch выводит результат сравнения треков nameA и nameB в формате format
+func writeMatch(w io.Writer, format string, res fennec.MatchResult, nameA, nameB string, match bool) error {
+	switch format {
+	case formatJSON:
+		return printJSON(w, matchOutputJSON{A: nameA, B: nameB, Match: match, matchJSON: newMatchJSON(res)})
+
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(append([]string{`a`, `b`, `match`}, matchCSVHeader...))
+		cw.Write(append([]string{nameA, nameB, strconv.FormatBool(match)}, matchCSVRow(res)...))
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		return writeMatchText(w, res, nameA, nameB)
+	}
+}
+
+// writeMatchText выводит результат сравнения в человекочитаемом виде
+func writeMatchText(w io.Writer, res fennec.MatchResult, nameA, nameB string) error {
+	if (res.LenA == 0) || (res.LenB == 0) {
+		_, err := fmt.Fprintln(w, `0 (no data)`)
+		return err
+	}
+
+	eq := 100 * res.Similarity
+
+	if res.Scale != 1 {
+		fmt.Fprintf(w, "%.3f (offset %.2f sec, scale %.3f)\n", eq, res.OffsetInSec, res.Scale)
+	} else {
+		fmt.Fprintf(w, "%.3f (offset %.2f sec)\n", eq, res.OffsetInSec)
+	}
+
+	if res.CntInOffset > 0 {
+		_, err := fmt.Fprintf(w, "matched region: %s sec of %s, %s sec of %s\n", res.RegionA, nameA, res.RegionB, nameB)
+		return err
+	}
+	return nil
+}
+
+// writeQueryResults выводит найденные по фрагменту clip треки каталога в формате format
+func writeQueryResults(w io.Writer, format string, results []fennec.QueryResult, names trackNames, clip string) error {
+	switch format {
+	case formatJSON:
+		return printJSON(w, queryOutputJSON{Clip: clip, Results: newQueryResultsJSON(results, names)})
+
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(append([]string{`rank`, `track_id`, `name`}, matchCSVHeader...))
+		for i, r := range results {
+			row := []string{strconv.Itoa(i + 1), strconv.FormatUint(uint64(r.TrackID), 10), names.name(r.TrackID)}
+			cw.Write(append(row, matchCSVRow(r.MatchResult)...))
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		if len(results) == 0 {
+			_, err := fmt.Fprintln(w, `no matches`)
+			return err
+		}
+
+		// в результатах поиска A - трек каталога, B - фрагмент
+		for i, r := range results {
+			_, err := fmt.Fprintf(w, "%d. %s  %.3f (offset %.2f sec, clip %s sec, track %s sec)\n",
+				i+1, names.name(r.TrackID), 100*r.Similarity, r.OffsetInSec, r.RegionB, r.RegionA,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// matchCSVRow значения колонок matchCSVHeader
+func matchCSVRow(res fennec.MatchResult) []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	return []string{
+		f(res.Similarity), f(res.Score), strconv.Itoa(res.Offset), f(res.OffsetInSec), f(res.Scale),
+		strconv.Itoa(res.CntInOffset), f(res.CntInOffsetPerc), strconv.Itoa(res.SumOffs), strconv.Itoa(res.CntOffs),
+		strconv.Itoa(res.MatchedHashes), strconv.Itoa(res.LenA), strconv.Itoa(res.LenB), f(res.ScoreK),
+		f(res.RegionA.Start), f(res.RegionA.End), f(res.RegionB.Start), f(res.RegionB.End),
+	}
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent(``, `  `)
+	return enc.Encode(v)
+}
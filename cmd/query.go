@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+)
+
+// runQuery ищет фрагмент в каталоге: fennec query [params] clip.mp3|fnc [track1.mp3 ...].
+// Код выхода: exitMatch (найден хоть один трек), exitNoMatch или exitError.
+func runQuery(args []string) int {
+	fs := flag.NewFlagSet(`query`, flag.ExitOnError)
+
+	indexDir := fs.String(`index`, ``, `Query disk index in this directory instead of track files`)
+	topK := fs.Int(`top`, 5, `Number of best matches to print`)
+	format := fs.String(`format`, formatText, `Output format: text, json or csv`)
+	minSimilarity := fs.Float64(`min-similarity`, defaultMinSimilarity, `Minimal similarity (0..1) of a printed match`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s query [params] clip.mp3|wav|flac|fnc [track1.mp3|wav|flac|fnc ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exit code: %d - match, %d - no match, %d - error\n", exitMatch, exitNoMatch, exitError)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if (*topK <= 0) || (fs.NArg() < 1) || ((*indexDir == ``) && (fs.NArg() < 2)) {
+		fs.Usage()
+		return exitError
+	}
+	if err := checkOutputFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	fp := fennec.DefaultFingerprinter()
+
+	clip, clipName, err := loadFingerprint(fp, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	catalog, names, closeCatalog, err := loadCatalog(fp, *indexDir, fs.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeCatalog()
+
+	results, err := catalog.QueryFingerprint(clip, *topK)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	// результаты отсортированы по убыванию Score, а не Similarity, поэтому фильтруются целиком
+	matched := results[:0]
+	for _, r := range results {
+		if isMatch(r.MatchResult, *minSimilarity) {
+			matched = append(matched, r)
+		}
+	}
+
+	if err := writeQueryResults(os.Stdout, *format, matched, names, clipName); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if len(matched) == 0 {
+		return exitNoMatch
+	}
+	return exitMatch
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// MIME тип .fnc отпечатка
+	fncContentType = `application/x-fennec-fnc`
+)
+
+type (
+	// server HTTP сервис построения, поиска и сравнения отпечатков
+	server struct {
+		fp      *fennec.Fingerprinter
+		matcher *fennec.Matcher
+		maxBody int64
+
+		// каталог загружается в фоне; до окончания загрузки /readyz и /query отвечают 503
+		mu      sync.RWMutex
+		catalog fingerprintCatalog
+		names   trackNames
+	}
+
+	// matchRequest тело запроса /match
+	matchRequest struct {
+		A fingerprintJSON `json:"a"`
+		B fingerprintJSON `json:"b"`
+	}
+
+	// httpError ошибка с HTTP статусом ответа
+	httpError struct {
+		status int
+		err    error
+	}
+)
+
+var (
+	errNotReady = errors.New(`Catalog is not loaded yet`)
+)
+
+func (err httpError) Error() string {
+	return err.err.Error()
+}
+
+func (err httpError) Unwrap() error {
+	return err.err
+}
+
+// runServe запускает HTTP сервис: fennec serve [params] [track1.mp3|wav|flac ...]
+func runServe(args []string) int {
+	fs := flag.NewFlagSet(`serve`, flag.ExitOnError)
+
+	addr := fs.String(`addr`, `:8080`, `Listen address`)
+	indexDir := fs.String(`index`, ``, `Serve queries from disk index in this directory instead of track files`)
+	maxBody := fs.Int64(`max-body`, 64<<20, `Max request body size in bytes`)
+	timeout := fs.Duration(`timeout`, 30*time.Second, `Per-request timeout`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [params] [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if (*maxBody <= 0) || (*timeout <= 0) {
+		fs.Usage()
+		return exitError
+	}
+
+	srv := &server{
+		fp:      fennec.DefaultFingerprinter(),
+		matcher: fennec.NewMatcher(),
+		maxBody: *maxBody,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// ошибка загрузки каталога останавливает сервер тем же штатным Shutdown, что и сигнал
+	closeCatalog := make(chan func(), 1)
+	catalogErr := make(chan error, 1)
+	go func() {
+		catalog, names, closeFn, err := loadCatalog(srv.fp, *indexDir, fs.Args())
+		if err != nil {
+			catalogErr <- err
+			stop()
+			return
+		}
+		closeCatalog <- closeFn
+
+		srv.mu.Lock()
+		srv.catalog, srv.names = catalog, names
+		srv.mu.Unlock()
+
+		log.Println(`catalog loaded`)
+	}()
+
+	httpSrv := &http.Server{
+		Addr:              *addr,
+		Handler:           srv.handler(*timeout),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *timeout,
+		// TimeoutHandler сам отвечает по истечении timeout, запас нужен на отправку ответа
+		WriteTimeout: *timeout + 10*time.Second,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	// ListenAndServe возвращается сразу после начала Shutdown, а выполняющиеся запросы дожидаемся через shutdownDone
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			log.Println(`shutdown:`, err)
+		}
+	}()
+
+	log.Println(`listening on`, *addr)
+	if err := httpSrv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Println(err)
+		return exitError
+	}
+	<-shutdownDone
+
+	select {
+	case err := <-catalogErr:
+		log.Println(`catalog:`, err)
+		return exitError
+	case closeFn := <-closeCatalog:
+		closeFn()
+	default:
+	}
+
+	return 0
+}
+
+func (srv *server) handler(timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(`/healthz`, srv.handleHealth)
+	mux.HandleFunc(`/readyz`, srv.handleReady)
+
+	api := http.NewServeMux()
+	api.Handle(`/fingerprint`, srv.post(srv.handleFingerprint))
+	api.Handle(`/query`, srv.post(srv.handleQuery))
+	api.Handle(`/match`, srv.post(srv.handleMatch))
+
+	timeoutBody, _ := json.Marshal(map[string]string{`error`: `request timeout`})
+	mux.Handle(`/`, http.TimeoutHandler(api, timeout, string(timeoutBody)))
+
+	return mux
+}
+
+// post оборачивает обработчик API: только POST, ограничение размера тела, ответ или ошибка в JSON
+func (srv *server) post(fn func(w http.ResponseWriter, r *http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set(`Allow`, http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{`error`: `method not allowed`})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, srv.maxBody)
+
+		resp, err := fn(w, r)
+		if err != nil {
+			status := errorStatus(err)
+			if status >= http.StatusInternalServerError {
+				log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+			}
+			writeJSON(w, status, map[string]string{`error`: err.Error()})
+			return
+		}
+
+		if resp != nil {
+			writeJSON(w, http.StatusOK, resp)
+		}
+	})
+}
+
+func (srv *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{`status`: `ok`})
+}
+
+func (srv *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if catalog, _ := srv.getCatalog(); catalog == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{`status`: `loading`})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{`status`: `ready`})
+}
+
+// handleFingerprint строит отпечаток по аудио из тела запроса. По умолчанию отвечает JSON (fingerprintJSON),
+// с ?output=fnc - файлом .fnc
+func (srv *server) handleFingerprint(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	format := bodyFormat(r)
+	if (format == `json`) || (format == `fnc`) {
+		return nil, httpError{http.StatusBadRequest, fmt.Errorf(`expected audio, got %s`, format)}
+	}
+
+	pcm, err := srv.fp.ReadAudioFrom(r.Body, format)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := srv.fp.Fingerprint(pcm)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.URL.Query().Get(`output`) != `fnc` {
+		return newFingerprintJSON(fp), nil
+	}
+
+	sampleRate := srv.fp.Config().SampleRate
+	f := &fennec.FncFile{
+		FncHeader: fennec.FncHeader{
+			Version:    fp.Version,
+			SampleRate: sampleRate,
+			Duration:   time.Duration(len(pcm)) * time.Second / time.Duration(sampleRate),
+		},
+		Hashes: fp.Hashes,
+	}
+
+	w.Header().Set(`Content-Type`, fncContentType)
+	if err := fennec.WriteFnc(w, f); err != nil {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+	}
+
+	return nil, nil
+}
+
+// handleQuery ищет в каталоге фрагмент: аудио, JSON отпечаток или .fnc. ?top=N - число результатов (по умолчанию 10)
+func (srv *server) handleQuery(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	catalog, names := srv.getCatalog()
+	if catalog == nil {
+		return nil, httpError{http.StatusServiceUnavailable, errNotReady}
+	}
+
+	topK := 10
+	if s := r.URL.Query().Get(`top`); s != `` {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, httpError{http.StatusBadRequest, fmt.Errorf(`bad top %q`, s)}
+		}
+		topK = n
+	}
+
+	fp, err := srv.readFingerprint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := catalog.QueryFingerprint(fp, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{`results`: newQueryResultsJSON(results, names)}, nil
+}
+
+// handleMatch сравнивает два JSON отпечатка (matchRequest)
+func (srv *server) handleMatch(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var req matchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, httpError{http.StatusBadRequest, err}
+	}
+
+	a, err := req.A.fingerprint()
+	if err != nil {
+		return nil, httpError{http.StatusBadRequest, err}
+	}
+	b, err := req.B.fingerprint()
+	if err != nil {
+		return nil, httpError{http.StatusBadRequest, err}
+	}
+
+	res, err := srv.matcher.MatchFingerprints(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMatchJSON(res), nil
+}
+
+func (srv *server) getCatalog() (fingerprintCatalog, trackNames) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	return srv.catalog, srv.names
+}
+
+// readFingerprint читает отпечаток из тела запроса: JSON, .fnc или аудио, из которого он строится
+func (srv *server) readFingerprint(r *http.Request) (fennec.Fingerprint, error) {
+	switch format := bodyFormat(r); format {
+	case `json`:
+		var fj fingerprintJSON
+		if err := json.NewDecoder(r.Body).Decode(&fj); err != nil {
+			return fennec.Fingerprint{}, httpError{http.StatusBadRequest, err}
+		}
+		fp, err := fj.fingerprint()
+		if err != nil {
+			return fennec.Fingerprint{}, httpError{http.StatusBadRequest, err}
+		}
+		return fp, nil
+
+	case `fnc`:
+		f, err := fennec.ReadFnc(r.Body)
+		if err != nil {
+			return fennec.Fingerprint{}, err
+		}
+		return f.Fingerprint(), nil
+
+	default:
+		return srv.fp.FingerprintFrom(r.Body, format)
+	}
+}
+
+// bodyFormat определяет формат тела запроса по параметру ?format= или по Content-Type.
+// Без Content-Type (или с типом MP3, application/octet-stream) тело считается MP3, прочие типы возвращаются как есть
+// и отвергаются декодером с ErrUnsupportedFormat.
+func bodyFormat(r *http.Request) string {
+	if format := r.URL.Query().Get(`format`); format != `` {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(`Content-Type`))
+	switch mediaType {
+	case `application/json`:
+		return `json`
+	case fncContentType:
+		return `fnc`
+	case `audio/wav`, `audio/wave`, `audio/x-wav`:
+		return `wav`
+	case `audio/flac`, `audio/x-flac`:
+		return `flac`
+	case ``, `audio/mpeg`, `audio/mp3`, `application/octet-stream`:
+		return `mp3`
+	default:
+		return mediaType
+	}
+}
+
+// errorStatus подбирает HTTP статус для ошибки обработки запроса
+func errorStatus(err error) int {
+	var (
+		he      httpError
+		maxErr  *http.MaxBytesError
+		jsonErr *json.SyntaxError
+	)
+
+	switch {
+	case errors.As(err, &maxErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &he):
+		return he.status
+	case errors.Is(err, fennec.ErrUnsupportedFormat), errors.Is(err, fennec.ErrUnsupportedSampleRate):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, fennec.ErrVersionMismatch), errors.Is(err, fennec.ErrTooShort), errors.Is(err, fennec.ErrSilentInput):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, fennec.ErrDecode), errors.Is(err, fennec.ErrBadFnc), errors.Is(err, fennec.ErrFncChecksum),
+		errors.Is(err, fennec.ErrWrongParams), errors.As(err, &jsonErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	fennec "github.com/atercattus/fennec-tiny"
+	"os"
+	"time"
+)
+
+// runTracklist размечает микс по трекам: fennec tracklist [params] mix.mp3 track1.mp3 [track2.flac ...].
+// Код выхода: exitMatch (найден хоть один трек), exitNoMatch или exitError.
+func runTracklist(args []string) int {
+	fs := flag.NewFlagSet(`tracklist`, flag.ExitOnError)
+
+	cfg := fennec.DefaultTracklistConfig
+	fs.DurationVar(&cfg.Window, `window`, cfg.Window, `Length of the mix window matched against tracks`)
+	fs.DurationVar(&cfg.Step, `step`, cfg.Step, `Step between mix windows`)
+	fs.Float64Var(&cfg.MinSimilarity, `min-similarity`, cfg.MinSimilarity, `Minimal similarity (0..1) of a window with a track`)
+	fs.IntVar(&cfg.StartHits, `start-hits`, cfg.StartHits, `Matched windows in a row to start a track`)
+	fs.IntVar(&cfg.StopMisses, `stop-misses`, cfg.StopMisses, `Unmatched windows in a row to stop a track`)
+	indexDir := fs.String(`index`, ``, `Use disk index in this directory instead of track files (track IDs are printed)`)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s tracklist [params] mix.mp3|wav|flac [track1.mp3|wav|flac ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exit code: %d - match, %d - no match, %d - error\n", exitMatch, exitNoMatch, exitError)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if (fs.NArg() < 1) || ((*indexDir == ``) && (fs.NArg() < 2)) {
+		fs.Usage()
+		return exitError
+	}
+
+	fp := fennec.DefaultFingerprinter()
+
+	catalog, names, closeCatalog, err := loadCatalog(fp, *indexDir, fs.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeCatalog()
+
+	entries, err := fennec.TracklistFromFile(catalog, fp, fs.Arg(0), cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	for _, e := range entries {
+		fmt.Printf("%s - %s  %s (from %s, confidence %.3f)\n",
+			fmtDuration(e.Start), fmtDuration(e.End), names.name(e.TrackID), fmtDuration(e.TrackOffset), e.Confidence,
+		)
+	}
+
+	if len(entries) == 0 {
+		return exitNoMatch
+	}
+	return exitMatch
+}
+
+// fmtDuration форматирует время как [h:]mm:ss
+func fmtDuration(d time.Duration) string {
+	sec := int(d.Round(time.Second) / time.Second)
+	if sec < 0 {
+		return `-` + fmtDuration(-d)
+	}
+	if sec >= 3600 {
+		return fmt.Sprintf(`%d:%02d:%02d`, sec/3600, sec/60%60, sec%60)
+	}
+	return fmt.Sprintf(`%02d:%02d`, sec/60, sec%60)
+}
@@ -0,0 +1,260 @@
+package fennec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/mjibson/go-dsp/window"
+)
+
+type (
+	// FingerprintConfig параметры построения отпечатка (спектрограмма, пики, пары пиков, хеши).
+	// Значения по умолчанию (DefaultConfig) совпадают с константами пакета.
+	FingerprintConfig struct {
+		// целевая Hz при получении PCM звука
+		SampleRate int
+
+		// Размер окна FFT (степень двойки). Число частотных bin'ов FFTWinSize/2 определяет число бит bin'а в хеше.
+		FFTWinSize int
+		// Величина перекрывания окон FFT
+		FFTOverlap int
+
+		// Ширина распространения пиков (см. параметр width в Gaussian.Make)
+		GaussianWidth float64
+		// коэффициент затухания огибающей пиков (0..1)
+		DecayingKoeff float64
+
+		// Максимум локальных пиков в одном фрейме, которые будут в итоге запомнены
+		MaxPeaksPerFrame int
+		// Максимальное число пар пиков, которое можно образовать с каждым отдельным пиком
+		MaxPairsPerPeak int
+
+		// lookahead по частотным диапазонам (bin'ы) и по времени при поиске пар пиков
+		LookaheadBinDiffMax  int
+		LookaheadTimeDiffMin int
+		LookaheadTimeDiffMax int
+
+		// Размерности частей хешей (под bin1 уходит log2(FFTWinSize/2) бит)
+		BinDiffBits  int
+		TimeDiffBits int
+	}
+
+	// hashLayout раскладка частей PeakPair по битам хеша
+	hashLayout struct {
+		binBits, binDiffBits, timeDiffBits uint
+	}
+
+	// Fingerprinter строит спектрограмму, пики и хеши по заданной конфигурации.
+	// Неизменяем после создания и безопасен для конкурентного использования.
+	Fingerprinter struct {
+		cfg     FingerprintConfig
+		layout  hashLayout
+		version FingerprintVersion
+
+		winFunc []float64
+	}
+)
+
+var (
+	ErrInvalidConfig = errors.New(`Invalid fingerprint config`)
+
+	DefaultConfig = FingerprintConfig{
+		SampleRate:           SampleRate,
+		FFTWinSize:           FFTWinSize,
+		FFTOverlap:           FFTOverlap,
+		GaussianWidth:        gaussianWidth,
+		DecayingKoeff:        decayingKoeff,
+		MaxPeaksPerFrame:     maxPeaksPerFrame,
+		MaxPairsPerPeak:      maxPairsPerPeak,
+		LookaheadBinDiffMax:  lookaheadBinDiffMax,
+		LookaheadTimeDiffMin: lookaheadTimeDiffMin,
+		LookaheadTimeDiffMax: lookaheadTimeDiffMax,
+		BinDiffBits:          binDiffBits,
+		TimeDiffBits:         timeDiffBits,
+	}
+
+	defaultLayout = hashLayout{binBits: binBits, binDiffBits: binDiffBits, timeDiffBits: timeDiffBits}
+
+	// используется функциями пакета, не принимающими конфигурацию явно
+	defaultFingerprinter = mustNewFingerprinter(DefaultConfig)
+)
+
+func invalidConfig(format string, args ...interface{}) error {
+	return fmt.Errorf(`%w: `+format, append([]interface{}{ErrInvalidConfig}, args...)...)
+}
+
+// Validate проверяет параметры на совместимость друг с другом и с разрядностью хеша
+func (cfg FingerprintConfig) Validate() error {
+	switch {
+	case cfg.SampleRate <= 0:
+		return invalidConfig(`SampleRate must be positive`)
+	case (cfg.FFTWinSize < 16) || (bits.OnesCount(uint(cfg.FFTWinSize)) != 1):
+		return invalidConfig(`FFTWinSize must be a power of 2 and at least 16`)
+	case (cfg.FFTOverlap < 0) || (cfg.FFTOverlap >= cfg.FFTWinSize):
+		return invalidConfig(`FFTOverlap must be in [0, FFTWinSize)`)
+	case cfg.GaussianWidth <= 0:
+		return invalidConfig(`GaussianWidth must be positive`)
+	case (cfg.DecayingKoeff <= 0) || (cfg.DecayingKoeff >= 1):
+		return invalidConfig(`DecayingKoeff must be in (0, 1)`)
+	case cfg.MaxPeaksPerFrame < 1:
+		return invalidConfig(`MaxPeaksPerFrame must be positive`)
+	case cfg.MaxPairsPerPeak < 1:
+		return invalidConfig(`MaxPairsPerPeak must be positive`)
+	case (cfg.BinDiffBits < 2) || (cfg.TimeDiffBits < 1):
+		return invalidConfig(`BinDiffBits must be at least 2 and TimeDiffBits at least 1`)
+	}
+
+	layout := cfg.layout()
+	if total := layout.binBits + layout.binDiffBits + layout.timeDiffBits; total > 32 {
+		return invalidConfig(`hash layout needs %d bits, only 32 available`, total)
+	}
+
+	if maxBinDiff := int(layout.binDiffMask() >> 1); (cfg.LookaheadBinDiffMax < 1) || (cfg.LookaheadBinDiffMax > maxBinDiff) {
+		return invalidConfig(`LookaheadBinDiffMax must be in [1, %d] for BinDiffBits=%d`, maxBinDiff, cfg.BinDiffBits)
+	}
+
+	if maxTimeDiff := int(layout.timeDiffMask()); (cfg.LookaheadTimeDiffMax < 1) || (cfg.LookaheadTimeDiffMax > maxTimeDiff) {
+		return invalidConfig(`LookaheadTimeDiffMax must be in [1, %d] for TimeDiffBits=%d`, maxTimeDiff, cfg.TimeDiffBits)
+	}
+
+	if (cfg.LookaheadTimeDiffMin < 1) || (cfg.LookaheadTimeDiffMin >= cfg.LookaheadTimeDiffMax) {
+		return invalidConfig(`LookaheadTimeDiffMin must be in [1, LookaheadTimeDiffMax)`)
+	}
+
+	return nil
+}
+
+func (cfg FingerprintConfig) layout() hashLayout {
+	return hashLayout{
+		binBits:      uint(bits.Len(uint(cfg.FFTWinSize/2)) - 1),
+		binDiffBits:  uint(cfg.BinDiffBits),
+		timeDiffBits: uint(cfg.TimeDiffBits),
+	}
+}
+
+// HashColsInOneSec сколько колонок спектрограммы (единиц Hash.Time) в одной секунде трека
+func (cfg FingerprintConfig) HashColsInOneSec() float64 {
+	return float64(cfg.SampleRate) / float64(cfg.FFTWinSize-cfg.FFTOverlap)
+}
+
+func (l hashLayout) binMask() uint      { return (1 << l.binBits) - 1 }
+func (l hashLayout) binDiffMask() uint  { return (1 << l.binDiffBits) - 1 }
+func (l hashLayout) timeDiffMask() uint { return (1 << l.timeDiffBits) - 1 }
+
+func (l hashLayout) encode(pp PeakPair) uint32 {
+	bin1 := pp.Bin1 & l.binMask()
+	binDiff := (pp.Bin2 - pp.Bin1) & l.binDiffMask()
+	timeDiff := pp.TimeDiff & l.timeDiffMask()
+
+	hash := (((bin1 << l.binDiffBits) | binDiff) << l.timeDiffBits) | timeDiff
+	return uint32(hash)
+}
+
+func (l hashLayout) decode(h Hash) PeakPair {
+	timeDiff := uint(h.Hash) & l.timeDiffMask()
+	binDiff := (uint(h.Hash) >> l.timeDiffBits) & l.binDiffMask()
+	bin1 := ((uint(h.Hash) >> l.timeDiffBits) >> l.binDiffBits) & l.binMask()
+
+	d := int(binDiff)
+	if (d & int((l.binDiffMask()+1)>>1)) > 0 {
+		d = -(int(l.binDiffMask()+1) - d)
+	}
+
+	bin2 := uint(d + int(bin1))
+
+	return PeakPair{
+		Time1:    uint(h.Time),
+		Bin1:     bin1,
+		Bin2:     bin2,
+		TimeDiff: timeDiff,
+	}
+}
+
+func NewFingerprinter(cfg FingerprintConfig) (*Fingerprinter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	fp := &Fingerprinter{
+		cfg:     cfg,
+		layout:  cfg.layout(),
+		version: cfg.Version(),
+		winFunc: window.Hann(cfg.FFTWinSize + 2)[1 : cfg.FFTWinSize+1],
+	}
+
+	return fp, nil
+}
+
+// DefaultFingerprinter возвращает Fingerprinter с DefaultConfig (его же используют функции пакета без явной конфигурации)
+func DefaultFingerprinter() *Fingerprinter {
+	return defaultFingerprinter
+}
+
+func mustNewFingerprinter(cfg FingerprintConfig) *Fingerprinter {
+	fp, err := NewFingerprinter(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return fp
+}
+
+// Config возвращает конфигурацию, с которой создан Fingerprinter
+func (fp *Fingerprinter) Config() FingerprintConfig {
+	return fp.cfg
+}
+
+// Spectre строит логарифмическую спектрограмму по моно PCM с частотой Config().SampleRate
+func (fp *Fingerprinter) Spectre(pcm []Float) ([][]Float, error) {
+	return fp.buildSpectre(pcm)
+}
+
+// Peaks находит пики в спектрограмме
+func (fp *Fingerprinter) Peaks(spectre [][]Float) []Peak {
+	return fp.findPeaksInSpectre(spectre)
+}
+
+// Hashes строит хеши по моно PCM с частотой Config().SampleRate
+func (fp *Fingerprinter) Hashes(pcm []Float) (Hashes, error) {
+	peaks, _, err := fp.findPeaks(pcm)
+	if err != nil {
+		return nil, err
+	}
+
+	return fp.FindHashes(peaks), nil
+}
+
+// ReadAudio читает аудио файл (см. ReadAudio) с частотой Config().SampleRate
+func (fp *Fingerprinter) ReadAudio(path string) ([]Float, error) {
+	return readAudio(path, fp.cfg.SampleRate)
+}
+
+// ReadAudioFrom читает из r аудио формата format (см. ReadAudioFrom) с частотой Config().SampleRate
+func (fp *Fingerprinter) ReadAudioFrom(r io.Reader, format string) ([]Float, error) {
+	return readAudioFrom(r, format, fp.cfg.SampleRate)
+}
+
+// HashesFromFile строит хеши по аудио файлу любого поддерживаемого формата
+func (fp *Fingerprinter) HashesFromFile(path string) (Hashes, error) {
+	pcm, err := fp.ReadAudio(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fp.Hashes(pcm)
+}
+
+func (fp *Fingerprinter) FindHashes(peaks []Peak) Hashes {
+	return fp.PeakPairsToHashes(fp.PeaksToPairs(peaks))
+}
+
+func (fp *Fingerprinter) PeakPairsToHashes(pairs []PeakPair) (hashes Hashes) {
+	hashes = make([]Hash, len(pairs))
+
+	for i, pair := range pairs {
+		hashes[i] = Hash{Time: uint32(pair.Time1), Hash: fp.layout.encode(pair)}
+	}
+
+	return
+}
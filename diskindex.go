@@ -0,0 +1,556 @@
+package fennec
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// после скольких постингов в памяти DiskIndex автоматически сбрасывает их в новый сегмент
+	maxPendingPostings = 1 << 22
+
+	// файл со списком удаленных треков: строки "TrackID boundary"
+	removedFileName = `removed`
+)
+
+type (
+	// DiskIndex персистентный индекс хешей в директории dir.
+	// Новые треки копятся в памяти и сбрасываются (Flush) в новый неизменяемый сегмент,
+	// сегменты читаются через mmap и периодически сливаются в один (Merge, StartMerger).
+	// Версия параметров отпечатков записывается в каждый сегмент, индекс с сегментами другой версии не откроется.
+	// Безопасен для конкурентного использования.
+	DiskIndex struct {
+		dir     string
+		matcher *Matcher
+
+		mu       sync.RWMutex
+		segments []*segment
+		pending  *Index
+		// уже отцепленные от pending, но еще не записанные на диск треки (остаются видимыми для запросов)
+		flushing   []*Index
+		pendingCnt int64
+		nextID     uint64
+
+		// удаленные треки: TrackID -> nextID на момент удаления. Постинги трека в сегментах с last < boundary
+		// не видны запросам и выбрасываются при Merge, а добавленные после удаления попадают в более новые сегменты.
+		// Карта не меняется на месте, а заменяется целиком.
+		removed map[TrackID]uint64
+
+		// сериализует слияния и сбросы, не блокируя запросы
+		writeMu sync.Mutex
+
+		stopMerger chan struct{}
+		mergerWg   sync.WaitGroup
+	}
+)
+
+// OpenDiskIndex открывает (или создает) индекс в директории dir для отпечатков, построенных с DefaultConfig
+func OpenDiskIndex(dir string) (*DiskIndex, error) {
+	return openDiskIndex(dir, NewMatcher())
+}
+
+// OpenDiskIndexWithConfig открывает (или создает) индекс в директории dir для отпечатков, построенных с конфигурацией cfg.
+// Если в dir уже есть сегменты другой версии, возвращается ошибка ErrVersionMismatch,
+// а если сегменты записаны прежним форматом - ErrSegmentVersion (индекс нужно построить заново).
+func OpenDiskIndexWithConfig(dir string, cfg FingerprintConfig) (*DiskIndex, error) {
+	m, err := NewMatcherWithConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return openDiskIndex(dir, m)
+}
+
+func openDiskIndex(dir string, m *Matcher) (*DiskIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// недописанные сегменты и списки удаленных от прерванных сбросов/слияний
+	for _, pattern := range []string{`tmp-seg-*`, `tmp-removed-*`} {
+		if tmps, err := filepath.Glob(filepath.Join(dir, pattern)); err == nil {
+			for _, tmp := range tmps {
+				os.Remove(tmp)
+			}
+		}
+	}
+
+	removed, err := readRemoved(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, `seg-*`+segmentFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	di := &DiskIndex{
+		dir:     dir,
+		matcher: m,
+		pending: newIndex(m),
+		removed: removed,
+	}
+
+	for _, path := range paths {
+		seg, err := openSegment(path)
+		if err == nil {
+			if err = checkVersion(m.version, seg.version); err != nil {
+				seg.close()
+			}
+		}
+		if err != nil {
+			di.closeSegments()
+			if err == ErrSegmentVersion {
+				err = fmt.Errorf(`%w: %s`, err, dir)
+			}
+			return nil, err
+		}
+		di.segments = append(di.segments, seg)
+	}
+
+	di.dropCoveredSegments()
+
+	for _, seg := range di.segments {
+		if seg.last >= di.nextID {
+			di.nextID = seg.last + 1
+		}
+	}
+	for _, boundary := range di.removed {
+		if boundary > di.nextID {
+			di.nextID = boundary
+		}
+	}
+
+	return di, nil
+}
+
+// dropCoveredSegments удаляет сегменты, уже вошедшие в результат слияния
+// (остаются, если процесс упал между записью слитого сегмента и удалением исходных)
+func (di *DiskIndex) dropCoveredSegments() {
+	sort.Slice(di.segments, func(i, j int) bool {
+		a, b := di.segments[i], di.segments[j]
+		if a.first != b.first {
+			return a.first < b.first
+		}
+		return a.last > b.last // более широкий диапазон первым
+	})
+
+	var kept []*segment
+	for _, seg := range di.segments {
+		if l := len(kept); (l > 0) && (seg.last <= kept[l-1].last) {
+			seg.close()
+			os.Remove(seg.path)
+			continue
+		}
+		kept = append(kept, seg)
+	}
+
+	di.segments = kept
+}
+
+// Add добавляет хеши трека. Они сразу доступны для поиска, а на диск попадают при очередном Flush.
+func (di *DiskIndex) Add(trackID TrackID, hashes Hashes) error {
+	di.mu.RLock()
+	di.pending.Add(trackID, hashes)
+	needFlush := atomic.AddInt64(&di.pendingCnt, int64(len(hashes))) >= maxPendingPostings
+	di.mu.RUnlock()
+
+	if needFlush {
+		return di.Flush()
+	}
+
+	return nil
+}
+
+// Version версия параметров отпечатков, хранимых в индексе
+func (di *DiskIndex) Version() FingerprintVersion {
+	return di.matcher.version
+}
+
+// AddFingerprint аналог Add, проверяющий версию отпечатка (ErrVersionMismatch)
+func (di *DiskIndex) AddFingerprint(trackID TrackID, fp Fingerprint) error {
+	if err := checkVersion(di.matcher.version, fp.Version); err != nil {
+		return err
+	}
+
+	return di.Add(trackID, fp.Hashes)
+}
+
+// Remove удаляет трек из индекса. Хеши трека сразу перестают находиться, а с диска удаляются при очередном Merge.
+// Тот же trackID после удаления можно добавить заново.
+func (di *DiskIndex) Remove(trackID TrackID) error {
+	// writeMu гарантирует, что отцепленные для сброса индексы сейчас не пишутся на диск
+	di.writeMu.Lock()
+	defer di.writeMu.Unlock()
+
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	removed := make(map[TrackID]uint64, len(di.removed)+1)
+	for id, boundary := range di.removed {
+		removed[id] = boundary
+	}
+	removed[trackID] = di.nextID
+
+	if err := writeRemoved(di.dir, removed); err != nil {
+		return err
+	}
+
+	di.pending.Remove(trackID)
+	for _, idx := range di.flushing {
+		idx.Remove(trackID)
+	}
+	di.removed = removed
+
+	return nil
+}
+
+// isRemoved проверяет, что постинги трека в сегменте seg удалены
+func (di *DiskIndex) isRemoved(seg *segment, trackID TrackID) bool {
+	boundary, ok := di.removed[trackID]
+	return ok && (seg.last < boundary)
+}
+
+// Flush записывает накопленные в памяти треки в новый сегмент
+func (di *DiskIndex) Flush() error {
+	di.writeMu.Lock()
+	defer di.writeMu.Unlock()
+
+	di.mu.Lock()
+	if di.pending.Len() > 0 {
+		di.flushing = append(di.flushing, di.pending)
+		di.pending = newIndex(di.matcher)
+		atomic.StoreInt64(&di.pendingCnt, 0)
+	}
+	flushing := append([]*Index(nil), di.flushing...)
+	di.mu.Unlock()
+
+	// отцепленные индексы больше никто не меняет, а запросы их только читают
+	for _, idx := range flushing {
+		di.mu.Lock()
+		id := di.nextID
+		di.nextID++
+		di.mu.Unlock()
+
+		path, err := writeSegmentFromIndex(di.dir, id, idx)
+		if err != nil {
+			return err
+		}
+
+		seg, err := openSegment(path)
+		if err != nil {
+			// индекс остается в flushing и при следующем Flush запишется под новым id,
+			// а этот файл после переоткрытия задвоил бы его постинги
+			os.Remove(path)
+			return err
+		}
+
+		di.mu.Lock()
+		di.segments = append(di.segments, seg)
+		di.flushing = di.flushing[1:]
+		di.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Merge сливает все сегменты индекса в один
+func (di *DiskIndex) Merge() error {
+	di.writeMu.Lock()
+	defer di.writeMu.Unlock()
+
+	di.mu.RLock()
+	segs := append([]*segment(nil), di.segments...)
+	removed := di.removed
+	di.mu.RUnlock()
+
+	// единственный сегмент переписывается, только если из него надо выбросить удаленные треки
+	if (len(segs) == 0) || ((len(segs) == 1) && (len(removed) == 0)) {
+		return nil
+	}
+
+	// сегменты и removed не меняются без writeMu, так что читать их можно без di.mu
+	path, err := mergeSegments(di.dir, segs, di.isRemoved)
+	if err != nil {
+		return err
+	}
+
+	merged, err := openSegment(path)
+	if err != nil {
+		// иначе при переоткрытии он закрыл бы собой исходные сегменты (если только не заменил единственный из них)
+		if (len(segs) > 1) || (segs[0].path != path) {
+			os.Remove(path)
+		}
+		return err
+	}
+
+	// удаление остается нужным, только пока есть не слитые сегменты, в которых трек может лежать
+	var keepRemoved map[TrackID]uint64
+	for id, boundary := range removed {
+		for _, seg := range di.segments[len(segs):] {
+			if seg.last < boundary {
+				if keepRemoved == nil {
+					keepRemoved = make(map[TrackID]uint64)
+				}
+				keepRemoved[id] = boundary
+				break
+			}
+		}
+	}
+
+	di.mu.Lock()
+	di.segments = append([]*segment{merged}, di.segments[len(segs):]...)
+	di.removed = keepRemoved
+	for _, seg := range segs {
+		seg.close()
+	}
+	di.mu.Unlock()
+
+	for _, seg := range segs {
+		if seg.path != merged.path {
+			os.Remove(seg.path)
+		}
+	}
+
+	// при падении до этого момента старый список лишь скрывает уже удаленные постинги
+	return writeRemoved(di.dir, keepRemoved)
+}
+
+// StartMerger запускает фоновое слияние: раз в interval, если сегментов больше maxSegments, они сливаются в один.
+// Ошибки слияния передаются в onError (если nil - пишутся в стандартный лог).
+func (di *DiskIndex) StartMerger(interval time.Duration, maxSegments int, onError func(err error)) {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	if di.stopMerger != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	di.stopMerger = stop
+
+	di.mergerWg.Add(1)
+	go func() {
+		defer di.mergerWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if di.SegmentsCnt() <= maxSegments {
+					continue
+				}
+				if err := di.Merge(); err != nil {
+					if onError != nil {
+						onError(err)
+					} else {
+						log.Printf("fennec: merge of disk index %s: %s", di.dir, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// MaxTrackID возвращает наибольший TrackID индекса (0 для пустого), включая удаленные, но еще не вычищенные слиянием треки
+func (di *DiskIndex) MaxTrackID() TrackID {
+	di.mu.RLock()
+	defer di.mu.RUnlock()
+
+	maxID := di.pending.maxTrackID()
+	for _, idx := range di.flushing {
+		if id := idx.maxTrackID(); id > maxID {
+			maxID = id
+		}
+	}
+	for _, seg := range di.segments {
+		// треки сегмента отсортированы по TrackID
+		if seg.tracksCnt > 0 {
+			if id := seg.track(seg.tracksCnt - 1).id; id > maxID {
+				maxID = id
+			}
+		}
+	}
+	for id := range di.removed {
+		if id > maxID {
+			maxID = id
+		}
+	}
+
+	return maxID
+}
+
+// SegmentsCnt возвращает текущее число сегментов на диске
+func (di *DiskIndex) SegmentsCnt() int {
+	di.mu.RLock()
+	defer di.mu.RUnlock()
+
+	return len(di.segments)
+}
+
+// Query ищет в индексе треки, содержащие фрагмент hashes (см. Index.Query)
+func (di *DiskIndex) Query(hashes Hashes, topK int) []QueryResult {
+	di.mu.RLock()
+	defer di.mu.RUnlock()
+
+	di.pending.mu.RLock()
+	defer di.pending.mu.RUnlock()
+
+	return queryPostings(di.matcher, hashes, topK, di.lookup, di.trackLen)
+}
+
+// QueryFingerprint аналог Query, проверяющий версию отпечатка (ErrVersionMismatch)
+func (di *DiskIndex) QueryFingerprint(fp Fingerprint, topK int) ([]QueryResult, error) {
+	if err := checkVersion(di.matcher.version, fp.Version); err != nil {
+		return nil, err
+	}
+
+	return di.Query(fp.Hashes, topK), nil
+}
+
+func (di *DiskIndex) lookup(hash uint32, fn func(p posting)) {
+	di.pending.lookup(hash, fn)
+	for _, idx := range di.flushing {
+		idx.lookup(hash, fn)
+	}
+	for _, seg := range di.segments {
+		if len(di.removed) == 0 {
+			seg.lookup(hash, fn)
+			continue
+		}
+		seg.lookup(hash, func(p posting) {
+			if !di.isRemoved(seg, p.Track) {
+				fn(p)
+			}
+		})
+	}
+}
+
+func (di *DiskIndex) trackLen(trackID TrackID) int {
+	l := di.pending.trackLen(trackID)
+	for _, idx := range di.flushing {
+		l += idx.trackLen(trackID)
+	}
+	for _, seg := range di.segments {
+		if !di.isRemoved(seg, trackID) {
+			l += seg.trackLen(trackID)
+		}
+	}
+	return l
+}
+
+// Close останавливает фоновое слияние, сбрасывает накопленное в памяти и закрывает сегменты
+func (di *DiskIndex) Close() error {
+	di.mu.Lock()
+	stop := di.stopMerger
+	di.stopMerger = nil
+	di.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		di.mergerWg.Wait()
+	}
+
+	err := di.Flush()
+
+	di.writeMu.Lock()
+	defer di.writeMu.Unlock()
+
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	di.closeSegments()
+
+	return err
+}
+
+func (di *DiskIndex) closeSegments() {
+	for _, seg := range di.segments {
+		seg.close()
+	}
+	di.segments = nil
+}
+
+// readRemoved читает список удаленных треков индекса в dir
+func readRemoved(dir string) (map[TrackID]uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, removedFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	removed := make(map[TrackID]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == `` {
+			continue
+		}
+
+		var (
+			id       TrackID
+			boundary uint64
+		)
+		if _, err := fmt.Sscanf(line, `%d %d`, &id, &boundary); err != nil {
+			return nil, ErrBadSegment
+		}
+		removed[id] = boundary
+	}
+
+	return removed, nil
+}
+
+// writeRemoved атомарно (через временный файл) записывает список удаленных треков. Пустой список удаляет файл.
+func writeRemoved(dir string, removed map[TrackID]uint64) (err error) {
+	path := filepath.Join(dir, removedFileName)
+
+	if len(removed) == 0 {
+		if err := os.Remove(path); (err != nil) && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	ids := make([]TrackID, 0, len(removed))
+	for id := range removed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		fmt.Fprintf(&buf, "%d %d\n", id, removed[id])
+	}
+
+	fd, err := os.CreateTemp(dir, `tmp-removed-*`)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			fd.Close()
+			os.Remove(fd.Name())
+		}
+	}()
+
+	if _, err = fd.Write(buf.Bytes()); err != nil {
+		return err
+	} else if err = fd.Sync(); err != nil {
+		return err
+	} else if err = fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fd.Name(), path)
+}
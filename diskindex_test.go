@@ -0,0 +1,276 @@
+package fennec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testQueryTop возвращает лучший результат поиска фрагмента (или нулевой, если ничего не найдено)
+func testQueryTop(t *testing.T, catalog Catalog, clip Hashes) QueryResult {
+	t.Helper()
+	if results := catalog.Query(clip, 1); len(results) > 0 {
+		return results[0]
+	}
+	return QueryResult{}
+}
+
+func testOpenDiskIndex(t *testing.T, dir string) *DiskIndex {
+	t.Helper()
+	di, err := OpenDiskIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return di
+}
+
+// testFillDiskIndex добавляет треки 1..cnt, сбрасывая каждый в отдельный сегмент
+func testFillDiskIndex(t *testing.T, di *DiskIndex, cnt int) []Hashes {
+	t.Helper()
+	tracks := make([]Hashes, cnt)
+	for i := range tracks {
+		tracks[i] = testTrackHashes(60, 20, int64(i+1))
+		if err := di.Add(TrackID(i+1), tracks[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := di.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tracks
+}
+
+// testCheckTracks проверяет, что фрагмент каждого трека находится именно в нем и с тем же числом совпадений, что в Index
+func testCheckTracks(t *testing.T, di *DiskIndex, tracks []Hashes, removed ...TrackID) {
+	t.Helper()
+
+	isRemoved := make(map[TrackID]bool)
+	for _, id := range removed {
+		isRemoved[id] = true
+	}
+
+	for i, track := range tracks {
+		id := TrackID(i + 1)
+		clip := testClip(track, 20, 10)
+
+		res := testQueryTop(t, di, clip)
+		if isRemoved[id] {
+			if res.TrackID == id {
+				t.Errorf("track %d: removed, but found", id)
+			}
+			continue
+		}
+
+		idx := NewIndex()
+		idx.Add(id, track)
+		expected := testQueryTop(t, idx, clip)
+		if expected.CntInOffset == 0 {
+			t.Fatalf("track %d: clip is not found even in Index", id)
+		}
+
+		if (res.TrackID != id) || (res.CntInOffset != expected.CntInOffset) {
+			t.Errorf("track %d: found %d with %d hashes in offset, expected %d", id, res.TrackID, res.CntInOffset, expected.CntInOffset)
+		}
+	}
+}
+
+func TestDiskIndexFlushReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	di := testOpenDiskIndex(t, dir)
+	tracks := testFillDiskIndex(t, di, 3)
+
+	// несброшенный трек тоже виден запросам и сбрасывается при Close
+	tracks = append(tracks, testTrackHashes(60, 20, 4))
+	if err := di.Add(4, tracks[3]); err != nil {
+		t.Fatal(err)
+	}
+	testCheckTracks(t, di, tracks)
+
+	if err := di.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	di = testOpenDiskIndex(t, dir)
+	defer di.Close()
+
+	if cnt := di.SegmentsCnt(); cnt != 4 {
+		t.Errorf("segments after reopen: %d, expected 4", cnt)
+	}
+	testCheckTracks(t, di, tracks)
+}
+
+func TestDiskIndexMergeRemove(t *testing.T) {
+	dir := t.TempDir()
+
+	di := testOpenDiskIndex(t, dir)
+	tracks := testFillDiskIndex(t, di, 4)
+
+	if err := di.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	testCheckTracks(t, di, tracks, 2)
+
+	if err := di.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	if cnt := di.SegmentsCnt(); cnt != 1 {
+		t.Errorf("segments after merge: %d, expected 1", cnt)
+	}
+	testCheckTracks(t, di, tracks, 2)
+
+	// удаленный ID можно добавить заново
+	tracks[1] = testTrackHashes(60, 20, 100)
+	if err := di.Add(2, tracks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := di.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	di = testOpenDiskIndex(t, dir)
+	defer di.Close()
+
+	testCheckTracks(t, di, tracks)
+
+	if err := di.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	testCheckTracks(t, di, tracks)
+
+	if _, err := os.Stat(filepath.Join(dir, removedFileName)); !os.IsNotExist(err) {
+		t.Errorf("list of removed tracks is kept after full merge: %v", err)
+	}
+}
+
+// TestDiskIndexCrashRecovery воспроизводит падение во время слияния: слитый сегмент уже на месте,
+// а исходные еще не удалены, плюс недописанный временный сегмент
+func TestDiskIndexCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	di := testOpenDiskIndex(t, dir)
+	tracks := testFillDiskIndex(t, di, 3)
+
+	oldSegs, err := filepath.Glob(filepath.Join(dir, `seg-*`+segmentFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := t.TempDir()
+	for _, p := range oldSegs {
+		copyTestFile(t, p, filepath.Join(backup, filepath.Base(p)))
+	}
+
+	if err := di.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	if err := di.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range oldSegs {
+		copyTestFile(t, filepath.Join(backup, filepath.Base(p)), p)
+	}
+	tmp := filepath.Join(dir, `tmp-seg-123`)
+	if err := os.WriteFile(tmp, []byte(`garbage`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	di = testOpenDiskIndex(t, dir)
+	defer di.Close()
+
+	if cnt := di.SegmentsCnt(); cnt != 1 {
+		t.Errorf("segments after recovery: %d, expected 1", cnt)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary segment is not removed: %v", err)
+	}
+	// покрытые слитым сегментом исходные не должны задваивать постинги
+	testCheckTracks(t, di, tracks)
+}
+
+func TestDiskIndexMaxTrackID(t *testing.T) {
+	dir := t.TempDir()
+
+	di := testOpenDiskIndex(t, dir)
+	if id := di.MaxTrackID(); id != 0 {
+		t.Errorf("empty index: max track %d", id)
+	}
+
+	testFillDiskIndex(t, di, 3)
+	if err := di.Remove(3); err != nil {
+		t.Fatal(err)
+	}
+	if id := di.MaxTrackID(); id != 3 {
+		t.Errorf("after remove: max track %d, expected 3", id)
+	}
+
+	if err := di.Add(7, testTrackHashes(10, 20, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if id := di.MaxTrackID(); id != 7 {
+		t.Errorf("with pending track: max track %d, expected 7", id)
+	}
+	if err := di.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	di = testOpenDiskIndex(t, dir)
+	defer di.Close()
+
+	if id := di.MaxTrackID(); id != 7 {
+		t.Errorf("after reopen: max track %d, expected 7", id)
+	}
+}
+
+func TestDiskIndexOutdatedSegment(t *testing.T) {
+	dir := t.TempDir()
+
+	di := testOpenDiskIndex(t, dir)
+	testFillDiskIndex(t, di, 1)
+	if err := di.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segs, err := filepath.Glob(filepath.Join(dir, `seg-*`+segmentFileExt))
+	if err != nil || len(segs) != 1 {
+		t.Fatalf("segments: %v %v", segs, err)
+	}
+	data, err := os.ReadFile(segs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(data[4:8], segmentVersion-1)
+	if err := os.WriteFile(segs[0], data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenDiskIndex(dir)
+	if !errors.Is(err, ErrSegmentVersion) || !strings.Contains(err.Error(), dir) {
+		t.Errorf("open of outdated index: %v", err)
+	}
+}
+
+func copyTestFile(t *testing.T, from, to string) {
+	t.Helper()
+
+	src, err := os.Open(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,1233 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: fennecpb/fennec.proto
+
+// gRPC API сервиса отпечатков: построение, сравнение, поиск по каталогу и потоковое опознавание.
+// Go код генерируется go generate (см. generate.go).
+
+package fennecpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AudioFormat int32
+
+const (
+	// по умолчанию MP3
+	AudioFormat_AUDIO_FORMAT_UNSPECIFIED AudioFormat = 0
+	AudioFormat_AUDIO_FORMAT_MP3         AudioFormat = 1
+	AudioFormat_AUDIO_FORMAT_WAV         AudioFormat = 2
+	AudioFormat_AUDIO_FORMAT_FLAC        AudioFormat = 3
+	// несжатый PCM, параметры задаются PcmFormat
+	AudioFormat_AUDIO_FORMAT_PCM AudioFormat = 4
+)
+
+// Enum value maps for AudioFormat.
+var (
+	AudioFormat_name = map[int32]string{
+		0: "AUDIO_FORMAT_UNSPECIFIED",
+		1: "AUDIO_FORMAT_MP3",
+		2: "AUDIO_FORMAT_WAV",
+		3: "AUDIO_FORMAT_FLAC",
+		4: "AUDIO_FORMAT_PCM",
+	}
+	AudioFormat_value = map[string]int32{
+		"AUDIO_FORMAT_UNSPECIFIED": 0,
+		"AUDIO_FORMAT_MP3":         1,
+		"AUDIO_FORMAT_WAV":         2,
+		"AUDIO_FORMAT_FLAC":        3,
+		"AUDIO_FORMAT_PCM":         4,
+	}
+)
+
+func (x AudioFormat) Enum() *AudioFormat {
+	p := new(AudioFormat)
+	*p = x
+	return p
+}
+
+func (x AudioFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AudioFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_fennecpb_fennec_proto_enumTypes[0].Descriptor()
+}
+
+func (AudioFormat) Type() protoreflect.EnumType {
+	return &file_fennecpb_fennec_proto_enumTypes[0]
+}
+
+func (x AudioFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AudioFormat.Descriptor instead.
+func (AudioFormat) EnumDescriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{0}
+}
+
+type StreamIdentifyResponse_Type int32
+
+const (
+	StreamIdentifyResponse_TYPE_UNSPECIFIED StreamIdentifyResponse_Type = 0
+	StreamIdentifyResponse_TYPE_START       StreamIdentifyResponse_Type = 1
+	StreamIdentifyResponse_TYPE_STOP        StreamIdentifyResponse_Type = 2
+)
+
+// Enum value maps for StreamIdentifyResponse_Type.
+var (
+	StreamIdentifyResponse_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_START",
+		2: "TYPE_STOP",
+	}
+	StreamIdentifyResponse_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_START":       1,
+		"TYPE_STOP":        2,
+	}
+)
+
+func (x StreamIdentifyResponse_Type) Enum() *StreamIdentifyResponse_Type {
+	p := new(StreamIdentifyResponse_Type)
+	*p = x
+	return p
+}
+
+func (x StreamIdentifyResponse_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StreamIdentifyResponse_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_fennecpb_fennec_proto_enumTypes[1].Descriptor()
+}
+
+func (StreamIdentifyResponse_Type) Type() protoreflect.EnumType {
+	return &file_fennecpb_fennec_proto_enumTypes[1]
+}
+
+func (x StreamIdentifyResponse_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StreamIdentifyResponse_Type.Descriptor instead.
+func (StreamIdentifyResponse_Type) EnumDescriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{13, 0}
+}
+
+// PcmFormat параметры несжатого PCM (fennec.RawPCMFormat)
+type PcmFormat struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	SampleRate uint32                 `protobuf:"varint,1,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	Channels   uint32                 `protobuf:"varint,2,opt,name=channels,proto3" json:"channels,omitempty"`
+	// 8 (беззнаковые), 16, 24, 32 для целых семплов; 32, 64 для float
+	BitsPerSample uint32 `protobuf:"varint,3,opt,name=bits_per_sample,json=bitsPerSample,proto3" json:"bits_per_sample,omitempty"`
+	Float         bool   `protobuf:"varint,4,opt,name=float,proto3" json:"float,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PcmFormat) Reset() {
+	*x = PcmFormat{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PcmFormat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PcmFormat) ProtoMessage() {}
+
+func (x *PcmFormat) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PcmFormat.ProtoReflect.Descriptor instead.
+func (*PcmFormat) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PcmFormat) GetSampleRate() uint32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *PcmFormat) GetChannels() uint32 {
+	if x != nil {
+		return x.Channels
+	}
+	return 0
+}
+
+func (x *PcmFormat) GetBitsPerSample() uint32 {
+	if x != nil {
+		return x.BitsPerSample
+	}
+	return 0
+}
+
+func (x *PcmFormat) GetFloat() bool {
+	if x != nil {
+		return x.Float
+	}
+	return false
+}
+
+type Audio struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        AudioFormat            `protobuf:"varint,1,opt,name=format,proto3,enum=fennec.v1.AudioFormat" json:"format,omitempty"`
+	Pcm           *PcmFormat             `protobuf:"bytes,2,opt,name=pcm,proto3" json:"pcm,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Audio) Reset() {
+	*x = Audio{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Audio) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Audio) ProtoMessage() {}
+
+func (x *Audio) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Audio.ProtoReflect.Descriptor instead.
+func (*Audio) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Audio) GetFormat() AudioFormat {
+	if x != nil {
+		return x.Format
+	}
+	return AudioFormat_AUDIO_FORMAT_UNSPECIFIED
+}
+
+func (x *Audio) GetPcm() *PcmFormat {
+	if x != nil {
+		return x.Pcm
+	}
+	return nil
+}
+
+func (x *Audio) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// Fingerprint отпечаток трека: версия параметров и хеши, i-й хеш - (times[i], hashes[i])
+type Fingerprint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       uint32                 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Times         []uint32               `protobuf:"varint,2,rep,packed,name=times,proto3" json:"times,omitempty"`
+	Hashes        []uint32               `protobuf:"varint,3,rep,packed,name=hashes,proto3" json:"hashes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Fingerprint) Reset() {
+	*x = Fingerprint{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Fingerprint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Fingerprint) ProtoMessage() {}
+
+func (x *Fingerprint) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Fingerprint.ProtoReflect.Descriptor instead.
+func (*Fingerprint) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Fingerprint) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Fingerprint) GetTimes() []uint32 {
+	if x != nil {
+		return x.Times
+	}
+	return nil
+}
+
+func (x *Fingerprint) GetHashes() []uint32 {
+	if x != nil {
+		return x.Hashes
+	}
+	return nil
+}
+
+type TimeSpan struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         float64                `protobuf:"fixed64,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           float64                `protobuf:"fixed64,2,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeSpan) Reset() {
+	*x = TimeSpan{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeSpan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeSpan) ProtoMessage() {}
+
+func (x *TimeSpan) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeSpan.ProtoReflect.Descriptor instead.
+func (*TimeSpan) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *TimeSpan) GetStart() float64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *TimeSpan) GetEnd() float64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+// MatchResult результат сравнения двух треков (fennec.MatchResult)
+type MatchResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Score         float64                `protobuf:"fixed64,1,opt,name=score,proto3" json:"score,omitempty"`
+	Similarity    float64                `protobuf:"fixed64,2,opt,name=similarity,proto3" json:"similarity,omitempty"`
+	Offset        int32                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	OffsetSec     float64                `protobuf:"fixed64,4,opt,name=offset_sec,json=offsetSec,proto3" json:"offset_sec,omitempty"`
+	Scale         float64                `protobuf:"fixed64,5,opt,name=scale,proto3" json:"scale,omitempty"`
+	CntInOffset   int32                  `protobuf:"varint,6,opt,name=cnt_in_offset,json=cntInOffset,proto3" json:"cnt_in_offset,omitempty"`
+	MatchedHashes int32                  `protobuf:"varint,7,opt,name=matched_hashes,json=matchedHashes,proto3" json:"matched_hashes,omitempty"`
+	LenA          int32                  `protobuf:"varint,8,opt,name=len_a,json=lenA,proto3" json:"len_a,omitempty"`
+	LenB          int32                  `protobuf:"varint,9,opt,name=len_b,json=lenB,proto3" json:"len_b,omitempty"`
+	RegionA       *TimeSpan              `protobuf:"bytes,10,opt,name=region_a,json=regionA,proto3" json:"region_a,omitempty"`
+	RegionB       *TimeSpan              `protobuf:"bytes,11,opt,name=region_b,json=regionB,proto3" json:"region_b,omitempty"`
+	Density       []int32                `protobuf:"varint,12,rep,packed,name=density,proto3" json:"density,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchResult) Reset() {
+	*x = MatchResult{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchResult) ProtoMessage() {}
+
+func (x *MatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchResult.ProtoReflect.Descriptor instead.
+func (*MatchResult) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MatchResult) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *MatchResult) GetSimilarity() float64 {
+	if x != nil {
+		return x.Similarity
+	}
+	return 0
+}
+
+func (x *MatchResult) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *MatchResult) GetOffsetSec() float64 {
+	if x != nil {
+		return x.OffsetSec
+	}
+	return 0
+}
+
+func (x *MatchResult) GetScale() float64 {
+	if x != nil {
+		return x.Scale
+	}
+	return 0
+}
+
+func (x *MatchResult) GetCntInOffset() int32 {
+	if x != nil {
+		return x.CntInOffset
+	}
+	return 0
+}
+
+func (x *MatchResult) GetMatchedHashes() int32 {
+	if x != nil {
+		return x.MatchedHashes
+	}
+	return 0
+}
+
+func (x *MatchResult) GetLenA() int32 {
+	if x != nil {
+		return x.LenA
+	}
+	return 0
+}
+
+func (x *MatchResult) GetLenB() int32 {
+	if x != nil {
+		return x.LenB
+	}
+	return 0
+}
+
+func (x *MatchResult) GetRegionA() *TimeSpan {
+	if x != nil {
+		return x.RegionA
+	}
+	return nil
+}
+
+func (x *MatchResult) GetRegionB() *TimeSpan {
+	if x != nil {
+		return x.RegionB
+	}
+	return nil
+}
+
+func (x *MatchResult) GetDensity() []int32 {
+	if x != nil {
+		return x.Density
+	}
+	return nil
+}
+
+type FingerprintRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Audio         *Audio                 `protobuf:"bytes,1,opt,name=audio,proto3" json:"audio,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FingerprintRequest) Reset() {
+	*x = FingerprintRequest{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FingerprintRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FingerprintRequest) ProtoMessage() {}
+
+func (x *FingerprintRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FingerprintRequest.ProtoReflect.Descriptor instead.
+func (*FingerprintRequest) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *FingerprintRequest) GetAudio() *Audio {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+type FingerprintResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fingerprint   *Fingerprint           `protobuf:"bytes,1,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	DurationSec   float64                `protobuf:"fixed64,2,opt,name=duration_sec,json=durationSec,proto3" json:"duration_sec,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FingerprintResponse) Reset() {
+	*x = FingerprintResponse{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FingerprintResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FingerprintResponse) ProtoMessage() {}
+
+func (x *FingerprintResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FingerprintResponse.ProtoReflect.Descriptor instead.
+func (*FingerprintResponse) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FingerprintResponse) GetFingerprint() *Fingerprint {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return nil
+}
+
+func (x *FingerprintResponse) GetDurationSec() float64 {
+	if x != nil {
+		return x.DurationSec
+	}
+	return 0
+}
+
+type MatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	A             *Fingerprint           `protobuf:"bytes,1,opt,name=a,proto3" json:"a,omitempty"`
+	B             *Fingerprint           `protobuf:"bytes,2,opt,name=b,proto3" json:"b,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchRequest) Reset() {
+	*x = MatchRequest{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchRequest) ProtoMessage() {}
+
+func (x *MatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchRequest.ProtoReflect.Descriptor instead.
+func (*MatchRequest) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MatchRequest) GetA() *Fingerprint {
+	if x != nil {
+		return x.A
+	}
+	return nil
+}
+
+func (x *MatchRequest) GetB() *Fingerprint {
+	if x != nil {
+		return x.B
+	}
+	return nil
+}
+
+type MatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        *MatchResult           `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchResponse) Reset() {
+	*x = MatchResponse{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchResponse) ProtoMessage() {}
+
+func (x *MatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchResponse.ProtoReflect.Descriptor instead.
+func (*MatchResponse) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MatchResponse) GetResult() *MatchResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type QueryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Query:
+	//
+	//	*QueryRequest_Fingerprint
+	//	*QueryRequest_Audio
+	Query isQueryRequest_Query `protobuf_oneof:"query"`
+	// число лучших результатов (0 - значение по умолчанию сервера)
+	TopK          int32 `protobuf:"varint,3,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *QueryRequest) GetQuery() isQueryRequest_Query {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetFingerprint() *Fingerprint {
+	if x != nil {
+		if x, ok := x.Query.(*QueryRequest_Fingerprint); ok {
+			return x.Fingerprint
+		}
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetAudio() *Audio {
+	if x != nil {
+		if x, ok := x.Query.(*QueryRequest_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+type isQueryRequest_Query interface {
+	isQueryRequest_Query()
+}
+
+type QueryRequest_Fingerprint struct {
+	Fingerprint *Fingerprint `protobuf:"bytes,1,opt,name=fingerprint,proto3,oneof"`
+}
+
+type QueryRequest_Audio struct {
+	Audio *Audio `protobuf:"bytes,2,opt,name=audio,proto3,oneof"`
+}
+
+func (*QueryRequest_Fingerprint) isQueryRequest_Query() {}
+
+func (*QueryRequest_Audio) isQueryRequest_Query() {}
+
+type QueryResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TrackId       uint32                 `protobuf:"varint,1,opt,name=track_id,json=trackId,proto3" json:"track_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Match         *MatchResult           `protobuf:"bytes,3,opt,name=match,proto3" json:"match,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResult) Reset() {
+	*x = QueryResult{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResult) ProtoMessage() {}
+
+func (x *QueryResult) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResult.ProtoReflect.Descriptor instead.
+func (*QueryResult) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *QueryResult) GetTrackId() uint32 {
+	if x != nil {
+		return x.TrackId
+	}
+	return 0
+}
+
+func (x *QueryResult) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *QueryResult) GetMatch() *MatchResult {
+	if x != nil {
+		return x.Match
+	}
+	return nil
+}
+
+type QueryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*QueryResult         `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueryResponse) GetResults() []*QueryResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type StreamIdentifyRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// формат потока, учитывается только в первом сообщении
+	Format AudioFormat `protobuf:"varint,1,opt,name=format,proto3,enum=fennec.v1.AudioFormat" json:"format,omitempty"`
+	Pcm    *PcmFormat  `protobuf:"bytes,2,opt,name=pcm,proto3" json:"pcm,omitempty"`
+	// очередной кусок потока
+	Chunk         []byte `protobuf:"bytes,3,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamIdentifyRequest) Reset() {
+	*x = StreamIdentifyRequest{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamIdentifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamIdentifyRequest) ProtoMessage() {}
+
+func (x *StreamIdentifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamIdentifyRequest.ProtoReflect.Descriptor instead.
+func (*StreamIdentifyRequest) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StreamIdentifyRequest) GetFormat() AudioFormat {
+	if x != nil {
+		return x.Format
+	}
+	return AudioFormat_AUDIO_FORMAT_UNSPECIFIED
+}
+
+func (x *StreamIdentifyRequest) GetPcm() *PcmFormat {
+	if x != nil {
+		return x.Pcm
+	}
+	return nil
+}
+
+func (x *StreamIdentifyRequest) GetChunk() []byte {
+	if x != nil {
+		return x.Chunk
+	}
+	return nil
+}
+
+type StreamIdentifyResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// начало или конец воспроизведения трека каталога (fennec.MonitorEvent)
+	Type           StreamIdentifyResponse_Type `protobuf:"varint,1,opt,name=type,proto3,enum=fennec.v1.StreamIdentifyResponse_Type" json:"type,omitempty"`
+	TrackId        uint32                      `protobuf:"varint,2,opt,name=track_id,json=trackId,proto3" json:"track_id,omitempty"`
+	Name           string                      `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	StreamStartSec float64                     `protobuf:"fixed64,4,opt,name=stream_start_sec,json=streamStartSec,proto3" json:"stream_start_sec,omitempty"`
+	StreamEndSec   float64                     `protobuf:"fixed64,5,opt,name=stream_end_sec,json=streamEndSec,proto3" json:"stream_end_sec,omitempty"`
+	TrackOffsetSec float64                     `protobuf:"fixed64,6,opt,name=track_offset_sec,json=trackOffsetSec,proto3" json:"track_offset_sec,omitempty"`
+	Confidence     float64                     `protobuf:"fixed64,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *StreamIdentifyResponse) Reset() {
+	*x = StreamIdentifyResponse{}
+	mi := &file_fennecpb_fennec_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamIdentifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamIdentifyResponse) ProtoMessage() {}
+
+func (x *StreamIdentifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fennecpb_fennec_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamIdentifyResponse.ProtoReflect.Descriptor instead.
+func (*StreamIdentifyResponse) Descriptor() ([]byte, []int) {
+	return file_fennecpb_fennec_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StreamIdentifyResponse) GetType() StreamIdentifyResponse_Type {
+	if x != nil {
+		return x.Type
+	}
+	return StreamIdentifyResponse_TYPE_UNSPECIFIED
+}
+
+func (x *StreamIdentifyResponse) GetTrackId() uint32 {
+	if x != nil {
+		return x.TrackId
+	}
+	return 0
+}
+
+func (x *StreamIdentifyResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StreamIdentifyResponse) GetStreamStartSec() float64 {
+	if x != nil {
+		return x.StreamStartSec
+	}
+	return 0
+}
+
+func (x *StreamIdentifyResponse) GetStreamEndSec() float64 {
+	if x != nil {
+		return x.StreamEndSec
+	}
+	return 0
+}
+
+func (x *StreamIdentifyResponse) GetTrackOffsetSec() float64 {
+	if x != nil {
+		return x.TrackOffsetSec
+	}
+	return 0
+}
+
+func (x *StreamIdentifyResponse) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+var File_fennecpb_fennec_proto protoreflect.FileDescriptor
+
+const file_fennecpb_fennec_proto_rawDesc = "" +
+	"\n" +
+	"\x15fennecpb/fennec.proto\x12\tfennec.v1\"\x86\x01\n" +
+	"\tPcmFormat\x12\x1f\n" +
+	"\vsample_rate\x18\x01 \x01(\rR\n" +
+	"sampleRate\x12\x1a\n" +
+	"\bchannels\x18\x02 \x01(\rR\bchannels\x12&\n" +
+	"\x0fbits_per_sample\x18\x03 \x01(\rR\rbitsPerSample\x12\x14\n" +
+	"\x05float\x18\x04 \x01(\bR\x05float\"s\n" +
+	"\x05Audio\x12.\n" +
+	"\x06format\x18\x01 \x01(\x0e2\x16.fennec.v1.AudioFormatR\x06format\x12&\n" +
+	"\x03pcm\x18\x02 \x01(\v2\x14.fennec.v1.PcmFormatR\x03pcm\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"U\n" +
+	"\vFingerprint\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12\x14\n" +
+	"\x05times\x18\x02 \x03(\rR\x05times\x12\x16\n" +
+	"\x06hashes\x18\x03 \x03(\rR\x06hashes\"2\n" +
+	"\bTimeSpan\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x01R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x01R\x03end\"\xff\x02\n" +
+	"\vMatchResult\x12\x14\n" +
+	"\x05score\x18\x01 \x01(\x01R\x05score\x12\x1e\n" +
+	"\n" +
+	"similarity\x18\x02 \x01(\x01R\n" +
+	"similarity\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x05R\x06offset\x12\x1d\n" +
+	"\n" +
+	"offset_sec\x18\x04 \x01(\x01R\toffsetSec\x12\x14\n" +
+	"\x05scale\x18\x05 \x01(\x01R\x05scale\x12\"\n" +
+	"\rcnt_in_offset\x18\x06 \x01(\x05R\vcntInOffset\x12%\n" +
+	"\x0ematched_hashes\x18\a \x01(\x05R\rmatchedHashes\x12\x13\n" +
+	"\x05len_a\x18\b \x01(\x05R\x04lenA\x12\x13\n" +
+	"\x05len_b\x18\t \x01(\x05R\x04lenB\x12.\n" +
+	"\bregion_a\x18\n" +
+	" \x01(\v2\x13.fennec.v1.TimeSpanR\aregionA\x12.\n" +
+	"\bregion_b\x18\v \x01(\v2\x13.fennec.v1.TimeSpanR\aregionB\x12\x18\n" +
+	"\adensity\x18\f \x03(\x05R\adensity\"<\n" +
+	"\x12FingerprintRequest\x12&\n" +
+	"\x05audio\x18\x01 \x01(\v2\x10.fennec.v1.AudioR\x05audio\"r\n" +
+	"\x13FingerprintResponse\x128\n" +
+	"\vfingerprint\x18\x01 \x01(\v2\x16.fennec.v1.FingerprintR\vfingerprint\x12!\n" +
+	"\fduration_sec\x18\x02 \x01(\x01R\vdurationSec\"Z\n" +
+	"\fMatchRequest\x12$\n" +
+	"\x01a\x18\x01 \x01(\v2\x16.fennec.v1.FingerprintR\x01a\x12$\n" +
+	"\x01b\x18\x02 \x01(\v2\x16.fennec.v1.FingerprintR\x01b\"?\n" +
+	"\rMatchResponse\x12.\n" +
+	"\x06result\x18\x01 \x01(\v2\x16.fennec.v1.MatchResultR\x06result\"\x92\x01\n" +
+	"\fQueryRequest\x12:\n" +
+	"\vfingerprint\x18\x01 \x01(\v2\x16.fennec.v1.FingerprintH\x00R\vfingerprint\x12(\n" +
+	"\x05audio\x18\x02 \x01(\v2\x10.fennec.v1.AudioH\x00R\x05audio\x12\x13\n" +
+	"\x05top_k\x18\x03 \x01(\x05R\x04topKB\a\n" +
+	"\x05query\"j\n" +
+	"\vQueryResult\x12\x19\n" +
+	"\btrack_id\x18\x01 \x01(\rR\atrackId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12,\n" +
+	"\x05match\x18\x03 \x01(\v2\x16.fennec.v1.MatchResultR\x05match\"A\n" +
+	"\rQueryResponse\x120\n" +
+	"\aresults\x18\x01 \x03(\v2\x16.fennec.v1.QueryResultR\aresults\"\x85\x01\n" +
+	"\x15StreamIdentifyRequest\x12.\n" +
+	"\x06format\x18\x01 \x01(\x0e2\x16.fennec.v1.AudioFormatR\x06format\x12&\n" +
+	"\x03pcm\x18\x02 \x01(\v2\x14.fennec.v1.PcmFormatR\x03pcm\x12\x14\n" +
+	"\x05chunk\x18\x03 \x01(\fR\x05chunk\"\xda\x02\n" +
+	"\x16StreamIdentifyResponse\x12:\n" +
+	"\x04type\x18\x01 \x01(\x0e2&.fennec.v1.StreamIdentifyResponse.TypeR\x04type\x12\x19\n" +
+	"\btrack_id\x18\x02 \x01(\rR\atrackId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12(\n" +
+	"\x10stream_start_sec\x18\x04 \x01(\x01R\x0estreamStartSec\x12$\n" +
+	"\x0estream_end_sec\x18\x05 \x01(\x01R\fstreamEndSec\x12(\n" +
+	"\x10track_offset_sec\x18\x06 \x01(\x01R\x0etrackOffsetSec\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\x01R\n" +
+	"confidence\";\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"TYPE_START\x10\x01\x12\r\n" +
+	"\tTYPE_STOP\x10\x02*\x84\x01\n" +
+	"\vAudioFormat\x12\x1c\n" +
+	"\x18AUDIO_FORMAT_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10AUDIO_FORMAT_MP3\x10\x01\x12\x14\n" +
+	"\x10AUDIO_FORMAT_WAV\x10\x02\x12\x15\n" +
+	"\x11AUDIO_FORMAT_FLAC\x10\x03\x12\x14\n" +
+	"\x10AUDIO_FORMAT_PCM\x10\x042\xa9\x02\n" +
+	"\x06Fennec\x12L\n" +
+	"\vFingerprint\x12\x1d.fennec.v1.FingerprintRequest\x1a\x1e.fennec.v1.FingerprintResponse\x12:\n" +
+	"\x05Match\x12\x17.fennec.v1.MatchRequest\x1a\x18.fennec.v1.MatchResponse\x12:\n" +
+	"\x05Query\x12\x17.fennec.v1.QueryRequest\x1a\x18.fennec.v1.QueryResponse\x12Y\n" +
+	"\x0eStreamIdentify\x12 .fennec.v1.StreamIdentifyRequest\x1a!.fennec.v1.StreamIdentifyResponse(\x010\x01B,Z*github.com/atercattus/fennec-tiny/fennecpbb\x06proto3"
+
+var (
+	file_fennecpb_fennec_proto_rawDescOnce sync.Once
+	file_fennecpb_fennec_proto_rawDescData []byte
+)
+
+func file_fennecpb_fennec_proto_rawDescGZIP() []byte {
+	file_fennecpb_fennec_proto_rawDescOnce.Do(func() {
+		file_fennecpb_fennec_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fennecpb_fennec_proto_rawDesc), len(file_fennecpb_fennec_proto_rawDesc)))
+	})
+	return file_fennecpb_fennec_proto_rawDescData
+}
+
+var file_fennecpb_fennec_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_fennecpb_fennec_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_fennecpb_fennec_proto_goTypes = []any{
+	(AudioFormat)(0),                 // 0: fennec.v1.AudioFormat
+	(StreamIdentifyResponse_Type)(0), // 1: fennec.v1.StreamIdentifyResponse.Type
+	(*PcmFormat)(nil),                // 2: fennec.v1.PcmFormat
+	(*Audio)(nil),                    // 3: fennec.v1.Audio
+	(*Fingerprint)(nil),              // 4: fennec.v1.Fingerprint
+	(*TimeSpan)(nil),                 // 5: fennec.v1.TimeSpan
+	(*MatchResult)(nil),              // 6: fennec.v1.MatchResult
+	(*FingerprintRequest)(nil),       // 7: fennec.v1.FingerprintRequest
+	(*FingerprintResponse)(nil),      // 8: fennec.v1.FingerprintResponse
+	(*MatchRequest)(nil),             // 9: fennec.v1.MatchRequest
+	(*MatchResponse)(nil),            // 10: fennec.v1.MatchResponse
+	(*QueryRequest)(nil),             // 11: fennec.v1.QueryRequest
+	(*QueryResult)(nil),              // 12: fennec.v1.QueryResult
+	(*QueryResponse)(nil),            // 13: fennec.v1.QueryResponse
+	(*StreamIdentifyRequest)(nil),    // 14: fennec.v1.StreamIdentifyRequest
+	(*StreamIdentifyResponse)(nil),   // 15: fennec.v1.StreamIdentifyResponse
+}
+var file_fennecpb_fennec_proto_depIdxs = []int32{
+	0,  // 0: fennec.v1.Audio.format:type_name -> fennec.v1.AudioFormat
+	2,  // 1: fennec.v1.Audio.pcm:type_name -> fennec.v1.PcmFormat
+	5,  // 2: fennec.v1.MatchResult.region_a:type_name -> fennec.v1.TimeSpan
+	5,  // 3: fennec.v1.MatchResult.region_b:type_name -> fennec.v1.TimeSpan
+	3,  // 4: fennec.v1.FingerprintRequest.audio:type_name -> fennec.v1.Audio
+	4,  // 5: fennec.v1.FingerprintResponse.fingerprint:type_name -> fennec.v1.Fingerprint
+	4,  // 6: fennec.v1.MatchRequest.a:type_name -> fennec.v1.Fingerprint
+	4,  // 7: fennec.v1.MatchRequest.b:type_name -> fennec.v1.Fingerprint
+	6,  // 8: fennec.v1.MatchResponse.result:type_name -> fennec.v1.MatchResult
+	4,  // 9: fennec.v1.QueryRequest.fingerprint:type_name -> fennec.v1.Fingerprint
+	3,  // 10: fennec.v1.QueryRequest.audio:type_name -> fennec.v1.Audio
+	6,  // 11: fennec.v1.QueryResult.match:type_name -> fennec.v1.MatchResult
+	12, // 12: fennec.v1.QueryResponse.results:type_name -> fennec.v1.QueryResult
+	0,  // 13: fennec.v1.StreamIdentifyRequest.format:type_name -> fennec.v1.AudioFormat
+	2,  // 14: fennec.v1.StreamIdentifyRequest.pcm:type_name -> fennec.v1.PcmFormat
+	1,  // 15: fennec.v1.StreamIdentifyResponse.type:type_name -> fennec.v1.StreamIdentifyResponse.Type
+	7,  // 16: fennec.v1.Fennec.Fingerprint:input_type -> fennec.v1.FingerprintRequest
+	9,  // 17: fennec.v1.Fennec.Match:input_type -> fennec.v1.MatchRequest
+	11, // 18: fennec.v1.Fennec.Query:input_type -> fennec.v1.QueryRequest
+	14, // 19: fennec.v1.Fennec.StreamIdentify:input_type -> fennec.v1.StreamIdentifyRequest
+	8,  // 20: fennec.v1.Fennec.Fingerprint:output_type -> fennec.v1.FingerprintResponse
+	10, // 21: fennec.v1.Fennec.Match:output_type -> fennec.v1.MatchResponse
+	13, // 22: fennec.v1.Fennec.Query:output_type -> fennec.v1.QueryResponse
+	15, // 23: fennec.v1.Fennec.StreamIdentify:output_type -> fennec.v1.StreamIdentifyResponse
+	20, // [20:24] is the sub-list for method output_type
+	16, // [16:20] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_fennecpb_fennec_proto_init() }
+func file_fennecpb_fennec_proto_init() {
+	if File_fennecpb_fennec_proto != nil {
+		return
+	}
+	file_fennecpb_fennec_proto_msgTypes[9].OneofWrappers = []any{
+		(*QueryRequest_Fingerprint)(nil),
+		(*QueryRequest_Audio)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fennecpb_fennec_proto_rawDesc), len(file_fennecpb_fennec_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fennecpb_fennec_proto_goTypes,
+		DependencyIndexes: file_fennecpb_fennec_proto_depIdxs,
+		EnumInfos:         file_fennecpb_fennec_proto_enumTypes,
+		MessageInfos:      file_fennecpb_fennec_proto_msgTypes,
+	}.Build()
+	File_fennecpb_fennec_proto = out.File
+	file_fennecpb_fennec_proto_goTypes = nil
+	file_fennecpb_fennec_proto_depIdxs = nil
+}
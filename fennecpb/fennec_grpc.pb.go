@@ -0,0 +1,243 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: fennecpb/fennec.proto
+
+// gRPC API сервиса отпечатков: построение, сравнение, поиск по каталогу и потоковое опознавание.
+// Go код генерируется go generate (см. generate.go).
+
+package fennecpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Fennec_Fingerprint_FullMethodName    = "/fennec.v1.Fennec/Fingerprint"
+	Fennec_Match_FullMethodName          = "/fennec.v1.Fennec/Match"
+	Fennec_Query_FullMethodName          = "/fennec.v1.Fennec/Query"
+	Fennec_StreamIdentify_FullMethodName = "/fennec.v1.Fennec/StreamIdentify"
+)
+
+// FennecClient is the client API for Fennec service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FennecClient interface {
+	// Fingerprint строит отпечаток по аудио
+	Fingerprint(ctx context.Context, in *FingerprintRequest, opts ...grpc.CallOption) (*FingerprintResponse, error)
+	// Match сравнивает два отпечатка
+	Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+	// Query ищет в каталоге трек по фрагменту (отпечатку или аудио)
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	// StreamIdentify принимает аудио поток чанками и сообщает о начале и конце воспроизведения треков каталога.
+	// В первом сообщении задается формат потока, чанки можно передавать начиная с него же.
+	StreamIdentify(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamIdentifyRequest, StreamIdentifyResponse], error)
+}
+
+type fennecClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFennecClient(cc grpc.ClientConnInterface) FennecClient {
+	return &fennecClient{cc}
+}
+
+func (c *fennecClient) Fingerprint(ctx context.Context, in *FingerprintRequest, opts ...grpc.CallOption) (*FingerprintResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FingerprintResponse)
+	err := c.cc.Invoke(ctx, Fennec_Fingerprint_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fennecClient) Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, Fennec_Match_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fennecClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, Fennec_Query_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fennecClient) StreamIdentify(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamIdentifyRequest, StreamIdentifyResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Fennec_ServiceDesc.Streams[0], Fennec_StreamIdentify_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamIdentifyRequest, StreamIdentifyResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fennec_StreamIdentifyClient = grpc.BidiStreamingClient[StreamIdentifyRequest, StreamIdentifyResponse]
+
+// FennecServer is the server API for Fennec service.
+// All implementations must embed UnimplementedFennecServer
+// for forward compatibility.
+type FennecServer interface {
+	// Fingerprint строит отпечаток по аудио
+	Fingerprint(context.Context, *FingerprintRequest) (*FingerprintResponse, error)
+	// Match сравнивает два отпечатка
+	Match(context.Context, *MatchRequest) (*MatchResponse, error)
+	// Query ищет в каталоге трек по фрагменту (отпечатку или аудио)
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+	// StreamIdentify принимает аудио поток чанками и сообщает о начале и конце воспроизведения треков каталога.
+	// В первом сообщении задается формат потока, чанки можно передавать начиная с него же.
+	StreamIdentify(grpc.BidiStreamingServer[StreamIdentifyRequest, StreamIdentifyResponse]) error
+	mustEmbedUnimplementedFennecServer()
+}
+
+// UnimplementedFennecServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFennecServer struct{}
+
+func (UnimplementedFennecServer) Fingerprint(context.Context, *FingerprintRequest) (*FingerprintResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Fingerprint not implemented")
+}
+func (UnimplementedFennecServer) Match(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedFennecServer) Query(context.Context, *QueryRequest) (*QueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedFennecServer) StreamIdentify(grpc.BidiStreamingServer[StreamIdentifyRequest, StreamIdentifyResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamIdentify not implemented")
+}
+func (UnimplementedFennecServer) mustEmbedUnimplementedFennecServer() {}
+func (UnimplementedFennecServer) testEmbeddedByValue()                {}
+
+// UnsafeFennecServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FennecServer will
+// result in compilation errors.
+type UnsafeFennecServer interface {
+	mustEmbedUnimplementedFennecServer()
+}
+
+func RegisterFennecServer(s grpc.ServiceRegistrar, srv FennecServer) {
+	// If the following call pancis, it indicates UnimplementedFennecServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Fennec_ServiceDesc, srv)
+}
+
+func _Fennec_Fingerprint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FingerprintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FennecServer).Fingerprint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Fennec_Fingerprint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FennecServer).Fingerprint(ctx, req.(*FingerprintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fennec_Match_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FennecServer).Match(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Fennec_Match_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FennecServer).Match(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fennec_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FennecServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Fennec_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FennecServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Fennec_StreamIdentify_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FennecServer).StreamIdentify(&grpc.GenericServerStream[StreamIdentifyRequest, StreamIdentifyResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Fennec_StreamIdentifyServer = grpc.BidiStreamingServer[StreamIdentifyRequest, StreamIdentifyResponse]
+
+// Fennec_ServiceDesc is the grpc.ServiceDesc for Fennec service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Fennec_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fennec.v1.Fennec",
+	HandlerType: (*FennecServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Fingerprint",
+			Handler:    _Fennec_Fingerprint_Handler,
+		},
+		{
+			MethodName: "Match",
+			Handler:    _Fennec_Match_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _Fennec_Query_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIdentify",
+			Handler:       _Fennec_StreamIdentify_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "fennecpb/fennec.proto",
+}
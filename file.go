@@ -1,7 +1,17 @@
 package fennec
 
 import (
+	"errors"
 	"io"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrWrongParams = errors.New(`Wrong params`)
+	ErrMmapFail    = errors.New(`mmap fail`)
+	// ErrDecode все ошибки бэкендов декодирования (битый поток и т.п.) удовлетворяют errors.Is(err, ErrDecode)
+	ErrDecode = errors.New(`Decoding error`)
 )
 
 const (
@@ -9,11 +19,38 @@ const (
 	int16ToFloat = Float(1 << 15)
 )
 
-func ReadMp3(path string) (pcm []Float, err error) {
-	rd, err := NewMP3Reader(path, SampleRate, 16)
-	if err != nil {
-		return nil, err
+type (
+	// decodeError оборачивает ошибку стороннего декодера
+	decodeError struct {
+		err error
+	}
+
+	// frameReader общий интерфейс покадровых декодеров
+	frameReader interface {
+		ReadFrame(buf []int16) ([]int16, error)
+		Close() error
 	}
+)
+
+func (err decodeError) Error() string {
+	return ErrDecode.Error() + `: ` + err.err.Error()
+}
+
+func (err decodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+func (err decodeError) Unwrap() error {
+	return err.err
+}
+
+// NewMP3ReaderAt создает декодер, читающий MP3 размера size из r
+func NewMP3ReaderAt(r io.ReaderAt, size int64, sampleRate int, bits int) (*mp3Reader, error) {
+	return NewMP3ReaderFrom(io.NewSectionReader(r, 0, size), sampleRate, bits)
+}
+
+// readFrames вычитывает все фреймы из rd и переводит их в float -1..1
+func readFrames(rd frameReader) (pcm []Float, err error) {
 	defer rd.Close()
 
 	var frame []int16
@@ -34,15 +71,215 @@ func ReadMp3(path string) (pcm []Float, err error) {
 	return pcm, nil
 }
 
+func ReadMp3(path string) (pcm []Float, err error) {
+	rd, err := NewMP3Reader(path, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadMp3Reader аналог ReadMp3 для MP3, читаемого из r
+func ReadMp3Reader(r io.Reader) (pcm []Float, err error) {
+	rd, err := NewMP3ReaderFrom(r, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadMp3ReaderAt аналог ReadMp3 для MP3 размера size, читаемого из r
+func ReadMp3ReaderAt(r io.ReaderAt, size int64) (pcm []Float, err error) {
+	rd, err := NewMP3ReaderAt(r, size, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
 func GenPeaksFromMp3(path string) ([]Peak, error) {
 	pcm, err := ReadMp3(path)
 	if err != nil {
 		return nil, err
 	}
 
-	spectre := buildSpectre(pcm)
+	peaks, _, err := findPeaks(pcm)
+	return peaks, err
+}
+
+// GenPeaksFromMp3Reader аналог GenPeaksFromMp3 для MP3, читаемого из r
+func GenPeaksFromMp3Reader(r io.Reader) ([]Peak, error) {
+	pcm, err := ReadMp3Reader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks, _, err := findPeaks(pcm)
+	return peaks, err
+}
+
+// GenPeaksFromMp3ReaderAt аналог GenPeaksFromMp3 для MP3 размера size, читаемого из r
+func GenPeaksFromMp3ReaderAt(r io.ReaderAt, size int64) ([]Peak, error) {
+	pcm, err := ReadMp3ReaderAt(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	peaks, _, err := findPeaks(pcm)
+	return peaks, err
+}
+
+// ReadWav читает WAV файл и приводит его к моно SampleRate
+func ReadWav(path string) (pcm []Float, err error) {
+	rd, err := NewWAVReader(path, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadWavReader аналог ReadWav для WAV, читаемого из r
+func ReadWavReader(r io.Reader) (pcm []Float, err error) {
+	rd, err := NewWAVReaderFrom(r, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadRawPCM читает несжатый PCM формата format из r и приводит его к моно SampleRate
+func ReadRawPCM(r io.Reader, format RawPCMFormat) (pcm []Float, err error) {
+	rd, err := NewRawPCMReader(r, format, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadFlac читает FLAC файл и приводит его к моно SampleRate
+func ReadFlac(path string) (pcm []Float, err error) {
+	rd, err := NewFLACReader(path, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadFlacReader аналог ReadFlac для FLAC, читаемого из r
+func ReadFlacReader(r io.Reader) (pcm []Float, err error) {
+	rd, err := NewFLACReaderFrom(r, SampleRate, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadAudio читает аудио файл, определяя формат по расширению (по умолчанию MP3)
+func ReadAudio(path string) (pcm []Float, err error) {
+	return readAudio(path, SampleRate)
+}
+
+// readAudio читает аудио файл и приводит его к моно sampleRate
+func readAudio(path string, sampleRate int) (pcm []Float, err error) {
+	var rd frameReader
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case `.wav`, `.wave`:
+		rd, err = NewWAVReader(path, sampleRate, 16)
+	case `.flac`:
+		rd, err = NewFLACReader(path, sampleRate, 16)
+	default:
+		rd, err = NewMP3Reader(path, sampleRate, 16)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+// ReadAudioFrom читает из r аудио формата format: расширение файла с точкой или без (mp3, wav, flac).
+// Пустой format означает MP3, для остальных возвращается ErrUnsupportedFormat.
+func ReadAudioFrom(r io.Reader, format string) (pcm []Float, err error) {
+	return readAudioFrom(r, format, SampleRate)
+}
+
+// readAudioFrom читает аудио из r и приводит его к моно sampleRate
+func readAudioFrom(r io.Reader, format string, sampleRate int) (pcm []Float, err error) {
+	var rd frameReader
+
+	switch strings.ToLower(strings.TrimPrefix(format, `.`)) {
+	case `wav`, `wave`:
+		rd, err = NewWAVReaderFrom(r, sampleRate, 16)
+	case `flac`:
+		rd, err = NewFLACReaderFrom(r, sampleRate, 16)
+	case `mp3`, ``:
+		rd, err = NewMP3ReaderFrom(r, sampleRate, 16)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return readFrames(rd)
+}
+
+func GenPeaksFromWav(path string) ([]Peak, error) {
+	pcm, err := ReadWav(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenPeaksFromPCM(pcm)
+}
+
+func GenPeaksFromFlac(path string) ([]Peak, error) {
+	pcm, err := ReadFlac(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenPeaksFromPCM(pcm)
+}
+
+// GenPeaksFromRawPCM строит пики по несжатому PCM формата format, читаемому из r
+func GenPeaksFromRawPCM(r io.Reader, format RawPCMFormat) ([]Peak, error) {
+	pcm, err := ReadRawPCM(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return GenPeaksFromPCM(pcm)
+}
+
+// GenPeaksFromPCM строит пики по уже подготовленному моно PCM с частотой SampleRate (float -1..1)
+func GenPeaksFromPCM(pcm []Float) ([]Peak, error) {
+	peaks, _, err := findPeaks(pcm)
+	return peaks, err
+}
+
+// GenPeaksFromFile строит пики по аудио файлу любого поддерживаемого формата (см. ReadAudio)
+func GenPeaksFromFile(path string) ([]Peak, error) {
+	peaks, _, err := GenPeaksFromFileWithSpectre(path)
+	return peaks, err
+}
+
+func GenPeaksFromFileWithSpectre(path string) ([]Peak, [][]Float, error) {
+	pcm, err := ReadAudio(path)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return findPeaksInSpectre(spectre), nil
+	return findPeaks(pcm)
 }
 
 func GenPeaksFromMp3WithSpectre(path string) ([]Peak, [][]Float, error) {
@@ -51,7 +288,5 @@ func GenPeaksFromMp3WithSpectre(path string) ([]Peak, [][]Float, error) {
 		return nil, nil, err
 	}
 
-	spectre := buildSpectre(pcm)
-
-	return findPeaksInSpectre(spectre), spectre, nil
+	return findPeaks(pcm)
 }
@@ -0,0 +1,267 @@
+package fennec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+const (
+	// версия алгоритма построения хешей. Повышается при изменениях spectre.go, меняющих хеши при тех же параметрах,
+	// и входит в Version каждой конфигурации.
+	fingerprintAlgoVersion = 1
+)
+
+type (
+	// FingerprintVersion идентификатор набора параметров (и версии алгоритма), с которым построены хеши.
+	// Хеши разных версий между собой несравнимы.
+	FingerprintVersion uint32
+
+	// Fingerprint хеши трека вместе с версией параметров, с которыми они построены
+	Fingerprint struct {
+		Version FingerprintVersion
+		Hashes  Hashes
+	}
+
+	// versionMismatchError попытка сравнить отпечатки (или индекс) разных версий
+	versionMismatchError struct {
+		expected, got FingerprintVersion
+	}
+
+	// FingerprintSet держит рядом несколько версий параметров (например, на время миграции хранилища на новые),
+	// строит отпечатки основной версией и сравнивает отпечатки любой из известных версий.
+	// Неизменяем после создания и безопасен для конкурентного использования.
+	FingerprintSet struct {
+		primary  FingerprintVersion
+		versions map[FingerprintVersion]fingerprintVersion
+		// порядок добавления версий (основная первой)
+		order []FingerprintVersion
+	}
+
+	fingerprintVersion struct {
+		fp      *Fingerprinter
+		matcher *Matcher
+	}
+)
+
+var (
+	// ErrVersionMismatch сравниваемые хеши построены с разными параметрами
+	ErrVersionMismatch = errors.New(`Fingerprint version mismatch`)
+	// ErrUnknownVersion версия отпечатка не зарегистрирована в FingerprintSet
+	ErrUnknownVersion = errors.New(`Unknown fingerprint version`)
+)
+
+func (v FingerprintVersion) String() string {
+	return fmt.Sprintf(`%08x`, uint32(v))
+}
+
+func (err versionMismatchError) Error() string {
+	return fmt.Sprintf(`%s: expected %s, got %s`, ErrVersionMismatch, err.expected, err.got)
+}
+
+func (err versionMismatchError) Is(target error) bool {
+	return target == ErrVersionMismatch
+}
+
+// checkVersion возвращает ошибку ErrVersionMismatch, если got != expected
+func checkVersion(expected, got FingerprintVersion) error {
+	if expected != got {
+		return versionMismatchError{expected: expected, got: got}
+	}
+	return nil
+}
+
+// Version вычисляет идентификатор набора параметров: два конфига с одинаковой версией дают одинаковые хеши
+func (cfg FingerprintConfig) Version() FingerprintVersion {
+	h := fnv.New32a()
+
+	var buf [8]byte
+	put := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+
+	put(fingerprintAlgoVersion)
+	for _, v := range []int{
+		cfg.SampleRate, cfg.FFTWinSize, cfg.FFTOverlap,
+		cfg.MaxPeaksPerFrame, cfg.MaxPairsPerPeak,
+		cfg.LookaheadBinDiffMax, cfg.LookaheadTimeDiffMin, cfg.LookaheadTimeDiffMax,
+		cfg.BinDiffBits, cfg.TimeDiffBits,
+	} {
+		put(uint64(int64(v)))
+	}
+	put(math.Float64bits(cfg.GaussianWidth))
+	put(math.Float64bits(cfg.DecayingKoeff))
+
+	if v := FingerprintVersion(h.Sum32()); v != 0 {
+		return v
+	}
+	// 0 зарезервирован под "версия неизвестна"
+	return 1
+}
+
+// Version версия параметров, с которыми Fingerprinter строит хеши
+func (fp *Fingerprinter) Version() FingerprintVersion {
+	return fp.version
+}
+
+// Fingerprint строит отпечаток по моно PCM с частотой Config().SampleRate
+func (fp *Fingerprinter) Fingerprint(pcm []Float) (Fingerprint, error) {
+	hashes, err := fp.Hashes(pcm)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	return Fingerprint{Version: fp.version, Hashes: hashes}, nil
+}
+
+// FingerprintFromFile строит отпечаток по аудио файлу любого поддерживаемого формата
+func (fp *Fingerprinter) FingerprintFromFile(path string) (Fingerprint, error) {
+	pcm, err := fp.ReadAudio(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	return fp.Fingerprint(pcm)
+}
+
+// FingerprintFrom строит отпечаток по аудио формата format (см. ReadAudioFrom), читаемому из r
+func (fp *Fingerprinter) FingerprintFrom(r io.Reader, format string) (Fingerprint, error) {
+	pcm, err := fp.ReadAudioFrom(r, format)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	return fp.Fingerprint(pcm)
+}
+
+// Version версия параметров, для которых создан Matcher
+func (m *Matcher) Version() FingerprintVersion {
+	return m.version
+}
+
+// MatchFingerprints сравнивает два отпечатка (см. Match), предварительно проверяя, что оба построены
+// с параметрами этого Matcher. Иначе возвращается ошибка ErrVersionMismatch.
+func (m *Matcher) MatchFingerprints(a, b Fingerprint) (MatchResult, error) {
+	if err := checkVersion(m.version, a.Version); err != nil {
+		return MatchResult{}, err
+	} else if err := checkVersion(m.version, b.Version); err != nil {
+		return MatchResult{}, err
+	}
+
+	return m.Match(a.Hashes, b.Hashes), nil
+}
+
+// NewFingerprintSet создает набор версий. Отпечатки строятся с primary, остальные конфиги используются только для сравнения.
+func NewFingerprintSet(primary FingerprintConfig, others ...FingerprintConfig) (*FingerprintSet, error) {
+	set := &FingerprintSet{
+		primary:  primary.Version(),
+		versions: make(map[FingerprintVersion]fingerprintVersion),
+	}
+
+	for _, cfg := range append([]FingerprintConfig{primary}, others...) {
+		version := cfg.Version()
+		if _, ok := set.versions[version]; ok {
+			continue
+		}
+
+		fp, err := NewFingerprinter(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		set.versions[version] = fingerprintVersion{fp: fp, matcher: newMatcher(cfg)}
+		set.order = append(set.order, version)
+	}
+
+	return set, nil
+}
+
+// Primary версия, с которой строятся новые отпечатки
+func (set *FingerprintSet) Primary() FingerprintVersion {
+	return set.primary
+}
+
+// Versions все известные версии, основная первой
+func (set *FingerprintSet) Versions() []FingerprintVersion {
+	return append([]FingerprintVersion(nil), set.order...)
+}
+
+// Fingerprinter возвращает Fingerprinter версии version
+func (set *FingerprintSet) Fingerprinter(version FingerprintVersion) (*Fingerprinter, bool) {
+	v, ok := set.versions[version]
+	return v.fp, ok
+}
+
+// Matcher возвращает Matcher версии version
+func (set *FingerprintSet) Matcher(version FingerprintVersion) (*Matcher, bool) {
+	v, ok := set.versions[version]
+	return v.matcher, ok
+}
+
+// Fingerprint строит отпечаток основной версией
+func (set *FingerprintSet) Fingerprint(pcm []Float) (Fingerprint, error) {
+	return set.versions[set.primary].fp.Fingerprint(pcm)
+}
+
+// FingerprintAll строит отпечатки всеми известными версиями (например, для переиндексации на время миграции).
+// pcm должен быть с частотой основной версии, версии с другой SampleRate дают ErrWrongParams.
+func (set *FingerprintSet) FingerprintAll(pcm []Float) ([]Fingerprint, error) {
+	sampleRate := set.versions[set.primary].fp.cfg.SampleRate
+
+	fps := make([]Fingerprint, 0, len(set.order))
+	for _, version := range set.order {
+		v := set.versions[version]
+		if v.fp.cfg.SampleRate != sampleRate {
+			return nil, ErrWrongParams
+		}
+
+		fp, err := v.fp.Fingerprint(pcm)
+		if err != nil {
+			return nil, err
+		}
+		fps = append(fps, fp)
+	}
+	return fps, nil
+}
+
+// Match сравнивает два отпечатка одной версии. Для разных версий возвращается ErrVersionMismatch,
+// для незарегистрированной - ErrUnknownVersion.
+func (set *FingerprintSet) Match(a, b Fingerprint) (MatchResult, error) {
+	if err := checkVersion(a.Version, b.Version); err != nil {
+		return MatchResult{}, err
+	}
+
+	v, ok := set.versions[a.Version]
+	if !ok {
+		return MatchResult{}, fmt.Errorf(`%w: %s`, ErrUnknownVersion, a.Version)
+	}
+
+	return v.matcher.Match(a.Hashes, b.Hashes), nil
+}
+
+// MatchAny сравнивает два трека, у каждого из которых может быть несколько отпечатков разных версий.
+// Используется общая для обоих версия (основная, если есть у обоих, иначе первая по порядку в наборе).
+func (set *FingerprintSet) MatchAny(a, b []Fingerprint) (MatchResult, error) {
+	for _, version := range set.order {
+		fa, okA := findFingerprint(a, version)
+		fb, okB := findFingerprint(b, version)
+		if okA && okB {
+			return set.versions[version].matcher.Match(fa.Hashes, fb.Hashes), nil
+		}
+	}
+
+	return MatchResult{}, ErrVersionMismatch
+}
+
+func findFingerprint(fps []Fingerprint, version FingerprintVersion) (Fingerprint, bool) {
+	for _, fp := range fps {
+		if fp.Version == version {
+			return fp, true
+		}
+	}
+	return Fingerprint{}, false
+}
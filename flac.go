@@ -0,0 +1,129 @@
+package fennec
+
+import (
+	"io"
+	"os"
+
+	"github.com/mewkiz/flac"
+)
+
+type (
+	// flacReader покадровый декодер FLAC с тем же приведением к моно sampleRate, что и у mp3Reader
+	flacReader struct {
+		stream *flac.Stream
+
+		left, right []int16
+		conv        pcmConverter
+	}
+)
+
+func NewFLACReader(path string, sampleRate int, bits int) (*flacReader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	}
+
+	stream, err := flac.Open(path)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, err
+		}
+		return nil, decodeError{err}
+	}
+
+	return newFLACReader(stream, sampleRate)
+}
+
+// NewFLACReaderFrom создает декодер, читающий FLAC из r
+func NewFLACReaderFrom(r io.Reader, sampleRate int, bits int) (*flacReader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	}
+
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, decodeError{err}
+	}
+
+	return newFLACReader(stream, sampleRate)
+}
+
+func newFLACReader(stream *flac.Stream, sampleRate int) (*flacReader, error) {
+	if err := checkSrcSampleRate(int(stream.Info.SampleRate), sampleRate); err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	rd := &flacReader{
+		stream: stream,
+		conv:   pcmConverter{sampleRate: sampleRate},
+	}
+
+	return rd, nil
+}
+
+func (rd *flacReader) Close() error {
+	if rd.stream == nil {
+		return ErrWrongParams
+	}
+	rd.stream.Close()
+	rd.stream = nil
+
+	return nil
+}
+
+// ReadFrame читает один PCM фрейм (см. mp3Reader.ReadFrame)
+func (rd *flacReader) ReadFrame(buf []int16) ([]int16, error) {
+	frame, err := rd.stream.ParseNext()
+	if err == io.EOF {
+		return rd.conv.finish(buf)
+	} else if err != nil {
+		return nil, decodeError{err}
+	}
+
+	channels := len(frame.Subframes)
+	if channels == 0 {
+		return buf[0:0], nil
+	}
+
+	bps := int(frame.BitsPerSample)
+	if bps == 0 {
+		bps = int(rd.stream.Info.BitsPerSample)
+	}
+
+	srcSampleRate := int(frame.SampleRate)
+	if srcSampleRate == 0 {
+		srcSampleRate = int(rd.stream.Info.SampleRate)
+	}
+
+	samplesCnt := len(frame.Subframes[0].Samples)
+
+	rd.left, rd.right = rd.left[0:0], rd.right[0:0]
+	for i := 0; i < samplesCnt; i++ {
+		if channels == 2 {
+			rd.left = append(rd.left, flacSampleToInt16(frame.Subframes[0].Samples[i], bps))
+			rd.right = append(rd.right, flacSampleToInt16(frame.Subframes[1].Samples[i], bps))
+			continue
+		}
+
+		// моно или многоканальный звук: просто усредняем все каналы
+		sum := 0
+		for _, sub := range frame.Subframes {
+			sum += int(flacSampleToInt16(sub.Samples[i], bps))
+		}
+		rd.left = append(rd.left, int16(sum/channels))
+	}
+
+	right := []int16(nil)
+	if channels == 2 {
+		right = rd.right
+	}
+
+	return rd.conv.convert(buf, rd.left, right, srcSampleRate), nil
+}
+
+func flacSampleToInt16(sample int32, bps int) int16 {
+	if bps > 16 {
+		return int16(sample >> uint(bps-16))
+	}
+	return int16(sample << uint(16-bps))
+}
@@ -0,0 +1,424 @@
+package fennec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Формат файла отпечатка .fnc:
+//
+//	header  magic "FNCF", версия формата (байт), FingerprintVersion (uint32 LE),
+//	        uvarint SampleRate, uvarint длительность в мс,
+//	        uvarint число метаданных, затем для каждой пары uvarint длина + ключ, uvarint длина + значение
+//	body    блоки: uvarint число хешей в блоке (0 - конец тела), затем для каждого хеша
+//	        uvarint приращение Time относительно предыдущего хеша и uvarint Hash
+//	crc     CRC32 (IEEE, uint32 LE) всего предыдущего содержимого
+//
+// Хеши в теле идут по возрастанию (Time, Hash), так что приращения времени маленькие и неотрицательные.
+
+const (
+	fncMagic         = "FNCF"
+	fncFormatVersion = 1
+
+	// ограничения, защищающие от огромных выделений памяти на битых файлах
+	fncMaxMetaCnt   = 1024
+	fncMaxMetaLen   = 64 * 1024
+	fncMaxBlockSize = 1 << 16
+)
+
+var (
+	ErrBadFnc      = errors.New(`Bad fingerprint file`)
+	ErrFncChecksum = errors.New(`Fingerprint file checksum mismatch`)
+	// ErrFncUnsorted в FncEncoder.Write переданы хеши раньше уже записанных
+	ErrFncUnsorted = errors.New(`Fingerprint hashes must be written in time order`)
+)
+
+type (
+	// FncHeader заголовок файла отпечатка
+	FncHeader struct {
+		Version    FingerprintVersion
+		SampleRate int
+		Duration   time.Duration
+		// произвольные метаданные трека (название, исполнитель, внешний id и т.п.)
+		Meta map[string]string
+	}
+
+	// FncFile отпечаток трека целиком, как он хранится в .fnc
+	FncFile struct {
+		FncHeader
+		Hashes Hashes
+	}
+
+	// FncEncoder потоково пишет отпечаток: заголовок при создании, затем хеши блоками (Write), затем Close
+	FncEncoder struct {
+		w   io.Writer
+		bw  *bufio.Writer
+		crc uint32
+
+		lastTime uint32
+		lastHash uint32
+		started  bool
+		closed   bool
+
+		buf   []byte
+		block Hashes
+	}
+
+	// FncDecoder потоково читает отпечаток, записанный FncEncoder
+	FncDecoder struct {
+		r   *bufio.Reader
+		crc uint32
+
+		hdr      FncHeader
+		lastTime uint32
+		done     bool
+	}
+)
+
+// Fingerprint возвращает хеши файла вместе с их версией
+func (f *FncFile) Fingerprint() Fingerprint {
+	return Fingerprint{Version: f.Version, Hashes: f.Hashes}
+}
+
+// NewFncEncoder записывает заголовок hdr в w и возвращает энкодер для хешей
+func NewFncEncoder(w io.Writer, hdr FncHeader) (*FncEncoder, error) {
+	if (len(hdr.Meta) > fncMaxMetaCnt) || (hdr.SampleRate < 0) || (hdr.Duration < 0) {
+		return nil, ErrWrongParams
+	}
+
+	enc := &FncEncoder{w: w, bw: bufio.NewWriter(w)}
+
+	enc.write([]byte(fncMagic))
+
+	var buf [4]byte
+	enc.write([]byte{fncFormatVersion})
+	binary.LittleEndian.PutUint32(buf[:], uint32(hdr.Version))
+	enc.write(buf[:])
+
+	enc.writeUvarint(uint64(hdr.SampleRate))
+	enc.writeUvarint(uint64(hdr.Duration / time.Millisecond))
+
+	// ключи по порядку, чтобы один и тот же отпечаток всегда давал один и тот же файл
+	keys := make([]string, 0, len(hdr.Meta))
+	for k := range hdr.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	enc.writeUvarint(uint64(len(keys)))
+	for _, k := range keys {
+		v := hdr.Meta[k]
+		if (len(k) > fncMaxMetaLen) || (len(v) > fncMaxMetaLen) {
+			return nil, ErrWrongParams
+		}
+		enc.writeString(k)
+		enc.writeString(v)
+	}
+
+	return enc, nil
+}
+
+func (enc *FncEncoder) write(b []byte) {
+	enc.crc = crc32.Update(enc.crc, crc32.IEEETable, b)
+	enc.bw.Write(b)
+}
+
+func (enc *FncEncoder) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	enc.write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (enc *FncEncoder) writeString(s string) {
+	enc.writeUvarint(uint64(len(s)))
+	enc.write([]byte(s))
+}
+
+// Write дописывает очередную порцию хешей. Внутри порции хеши могут идти в любом порядке,
+// но не раньше (по Time) уже записанных, иначе ErrFncUnsorted.
+func (enc *FncEncoder) Write(hashes Hashes) error {
+	if enc.closed {
+		return ErrWrongParams
+	}
+
+	enc.block = append(enc.block[0:0], hashes...)
+	sort.Sort(hashesByTimeHash(enc.block))
+
+	if (len(enc.block) > 0) && enc.started {
+		first := enc.block[0]
+		if (first.Time < enc.lastTime) || ((first.Time == enc.lastTime) && (first.Hash < enc.lastHash)) {
+			return ErrFncUnsorted
+		}
+	}
+
+	for block := enc.block; len(block) > 0; {
+		n := minInt(len(block), fncMaxBlockSize)
+		enc.writeBlock(block[:n])
+		block = block[n:]
+	}
+
+	return nil
+}
+
+func (enc *FncEncoder) writeBlock(block Hashes) {
+	enc.buf = binary.AppendUvarint(enc.buf[0:0], uint64(len(block)))
+	for _, h := range block {
+		enc.buf = binary.AppendUvarint(enc.buf, uint64(h.Time-enc.lastTime))
+		enc.buf = binary.AppendUvarint(enc.buf, uint64(h.Hash))
+		enc.lastTime, enc.lastHash = h.Time, h.Hash
+	}
+	enc.started = true
+
+	enc.write(enc.buf)
+}
+
+// Close завершает тело и дописывает контрольную сумму. Исходный io.Writer не закрывается.
+func (enc *FncEncoder) Close() error {
+	if enc.closed {
+		return nil
+	}
+	enc.closed = true
+
+	enc.write([]byte{0})
+	if err := enc.bw.Flush(); err != nil {
+		return err
+	}
+
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], enc.crc)
+	_, err := enc.w.Write(buf[:])
+	return err
+}
+
+// NewFncDecoder читает заголовок из r и возвращает декодер для хешей
+func NewFncDecoder(r io.Reader) (*FncDecoder, error) {
+	dec := &FncDecoder{r: bufio.NewReader(r)}
+
+	var buf [9]byte
+	if err := dec.read(buf[:]); err != nil {
+		return nil, err
+	}
+
+	if (string(buf[0:4]) != fncMagic) || (buf[4] != fncFormatVersion) {
+		return nil, ErrBadFnc
+	}
+	dec.hdr.Version = FingerprintVersion(binary.LittleEndian.Uint32(buf[5:9]))
+
+	sampleRate, err := dec.readUvarint(1 << 31)
+	if err != nil {
+		return nil, err
+	}
+	dec.hdr.SampleRate = int(sampleRate)
+
+	durationMs, err := dec.readUvarint(1 << 62 / uint64(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	dec.hdr.Duration = time.Duration(durationMs) * time.Millisecond
+
+	metaCnt, err := dec.readUvarint(fncMaxMetaCnt)
+	if err != nil {
+		return nil, err
+	}
+	if metaCnt > 0 {
+		dec.hdr.Meta = make(map[string]string, metaCnt)
+	}
+	for i := uint64(0); i < metaCnt; i++ {
+		k, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		dec.hdr.Meta[k] = v
+	}
+
+	return dec, nil
+}
+
+// Header возвращает заголовок файла
+func (dec *FncDecoder) Header() FncHeader {
+	return dec.hdr
+}
+
+func (dec *FncDecoder) read(b []byte) error {
+	if _, err := io.ReadFull(dec.r, b); err != nil {
+		return dec.wrapEOF(err)
+	}
+	dec.crc = crc32.Update(dec.crc, crc32.IEEETable, b)
+	return nil
+}
+
+// readUvarint читает uvarint (см. binary.PutUvarint), не превышающий max
+func (dec *FncDecoder) readUvarint(max uint64) (uint64, error) {
+	var b [1]byte
+
+	v := uint64(0)
+	for shift := uint(0); shift < 64; shift += 7 {
+		if err := dec.read(b[:]); err != nil {
+			return 0, err
+		}
+
+		v |= uint64(b[0]&0x7f) << shift
+		if b[0] < 0x80 {
+			if v > max {
+				return 0, ErrBadFnc
+			}
+			return v, nil
+		}
+	}
+
+	return 0, ErrBadFnc
+}
+
+func (dec *FncDecoder) readString() (string, error) {
+	l, err := dec.readUvarint(fncMaxMetaLen)
+	if err != nil {
+		return ``, err
+	}
+
+	b := make([]byte, l)
+	if err := dec.read(b); err != nil {
+		return ``, err
+	}
+	return string(b), nil
+}
+
+// wrapEOF обрыв файла посередине считается повреждением, а не нормальным концом
+func (dec *FncDecoder) wrapEOF(err error) error {
+	if (err == io.EOF) || (err == io.ErrUnexpectedEOF) {
+		return ErrBadFnc
+	}
+	return err
+}
+
+// Next читает очередной блок хешей (buf используется как буфер под ответ).
+// После последнего блока проверяется контрольная сумма и возвращается io.EOF (или ErrFncChecksum).
+func (dec *FncDecoder) Next(buf Hashes) (Hashes, error) {
+	if dec.done {
+		return nil, io.EOF
+	}
+
+	cnt, err := dec.readUvarint(fncMaxBlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if cnt == 0 {
+		dec.done = true
+
+		expected := dec.crc
+		var crc [4]byte
+		if _, err := io.ReadFull(dec.r, crc[:]); err != nil {
+			return nil, dec.wrapEOF(err)
+		} else if binary.LittleEndian.Uint32(crc[:]) != expected {
+			return nil, ErrFncChecksum
+		}
+		return nil, io.EOF
+	}
+
+	buf = buf[0:0]
+	for i := uint64(0); i < cnt; i++ {
+		dt, err := dec.readUvarint(math.MaxUint32)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := dec.readUvarint(math.MaxUint32)
+		if err != nil {
+			return nil, err
+		}
+
+		t := uint64(dec.lastTime) + dt
+		if t > math.MaxUint32 {
+			return nil, ErrBadFnc
+		}
+		dec.lastTime = uint32(t)
+
+		buf = append(buf, Hash{Time: dec.lastTime, Hash: uint32(hash)})
+	}
+
+	return buf, nil
+}
+
+// WriteFnc записывает отпечаток f в w целиком
+func WriteFnc(w io.Writer, f *FncFile) error {
+	enc, err := NewFncEncoder(w, f.FncHeader)
+	if err != nil {
+		return err
+	}
+
+	if err := enc.Write(f.Hashes); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// ReadFnc читает отпечаток из r целиком. Хеши возвращаются по возрастанию (Time, Hash).
+func ReadFnc(r io.Reader) (*FncFile, error) {
+	dec, err := NewFncDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FncFile{FncHeader: dec.Header()}
+
+	var block Hashes
+	for {
+		if block, err = dec.Next(block); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		f.Hashes = append(f.Hashes, block...)
+	}
+
+	return f, nil
+}
+
+// WriteFncFile атомарно (через временный файл) записывает отпечаток в файл path
+func WriteFncFile(path string, f *FncFile) (err error) {
+	fd, err := os.CreateTemp(filepath.Dir(path), `tmp-fnc-*`)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			fd.Close()
+			os.Remove(fd.Name())
+		}
+	}()
+
+	// CreateTemp создает файл с правами 0600, а отпечаток должен читаться как обычный файл
+	if err = WriteFnc(fd, f); err != nil {
+		return err
+	} else if err = fd.Chmod(0644); err != nil {
+		return err
+	} else if err = fd.Sync(); err != nil {
+		return err
+	} else if err = fd.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fd.Name(), path)
+}
+
+// ReadFncFile читает отпечаток из файла path
+func ReadFncFile(path string) (*FncFile, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return ReadFnc(fd)
+}
@@ -0,0 +1,163 @@
+package fennec
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testFnc отпечаток с метаданными, одинаковыми Time, большим разрывом во времени и числом хешей больше блока
+func testFnc() *FncFile {
+	f := &FncFile{
+		FncHeader: FncHeader{
+			Version:    DefaultFingerprinter().Version(),
+			SampleRate: SampleRate,
+			Duration:   3*time.Minute + 25500*time.Millisecond,
+			Meta:       map[string]string{`name`: `Трек №1`, `artist`: `someone`, `empty`: ``},
+		},
+	}
+
+	for i := 0; i < fncMaxBlockSize+100; i++ {
+		f.Hashes = append(f.Hashes, Hash{Time: uint32(i / 3), Hash: uint32(i * 7919)})
+	}
+	f.Hashes = append(f.Hashes,
+		Hash{Time: math.MaxUint32 - 1, Hash: 5},
+		Hash{Time: math.MaxUint32, Hash: math.MaxUint32},
+		Hash{Time: math.MaxUint32, Hash: 0},
+	)
+
+	return f
+}
+
+func testWriteFnc(t *testing.T, f *FncFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteFnc(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFncRoundTrip(t *testing.T) {
+	f := testFnc()
+
+	// в файле хеши упорядочены по (Time, Hash), а на вход могут идти в любом порядке
+	expected := append(Hashes(nil), f.Hashes...)
+	sort.Sort(hashesByTimeHash(expected))
+	for i, j := 0, len(f.Hashes)-1; i < j; i, j = i+1, j-1 {
+		f.Hashes[i], f.Hashes[j] = f.Hashes[j], f.Hashes[i]
+	}
+
+	data := testWriteFnc(t, f)
+	if !bytes.Equal(data, testWriteFnc(t, f)) {
+		t.Error("same fingerprint gives different files")
+	}
+
+	got, err := ReadFnc(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.FncHeader, f.FncHeader) {
+		t.Errorf("header %+v, expected %+v", got.FncHeader, f.FncHeader)
+	}
+	if !reflect.DeepEqual(got.Hashes, expected) {
+		t.Errorf("got %d hashes differing from %d written", len(got.Hashes), len(expected))
+	}
+}
+
+func TestFncCorrupted(t *testing.T) {
+	data := testWriteFnc(t, testFnc())
+
+	corrupt := func(fn func(data []byte) []byte) []byte {
+		return fn(append([]byte(nil), data...))
+	}
+
+	cases := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		// младший бит последнего байта последнего хеша (перед маркером конца и CRC): структура не меняется
+		{`flipped body byte`, corrupt(func(d []byte) []byte { d[len(d)-6] ^= 1; return d }), ErrFncChecksum},
+		{`flipped checksum`, corrupt(func(d []byte) []byte { d[len(d)-1] ^= 1; return d }), ErrFncChecksum},
+		{`bad magic`, corrupt(func(d []byte) []byte { d[0] = 'X'; return d }), ErrBadFnc},
+		{`bad format version`, corrupt(func(d []byte) []byte { d[4]++; return d }), ErrBadFnc},
+		{`truncated header`, data[:6], ErrBadFnc},
+		{`truncated body`, data[:len(data)/2], ErrBadFnc},
+		{`truncated checksum`, data[:len(data)-2], ErrBadFnc},
+		{`empty`, nil, ErrBadFnc},
+	}
+
+	for _, c := range cases {
+		if _, err := ReadFnc(bytes.NewReader(c.data)); !errors.Is(err, c.expected) {
+			t.Errorf("%s: %v, expected %v", c.name, err, c.expected)
+		}
+	}
+}
+
+func TestFncEncoderUnsorted(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewFncEncoder(&buf, FncHeader{Version: DefaultFingerprinter().Version()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Write(Hashes{{Time: 100, Hash: 2}, {Time: 90, Hash: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	// тот же Time с большим Hash допустим
+	if err := enc.Write(Hashes{{Time: 100, Hash: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Write(Hashes{{Time: 100, Hash: 1}}); !errors.Is(err, ErrFncUnsorted) {
+		t.Errorf("same time, smaller hash: %v", err)
+	}
+	if err := enc.Write(Hashes{{Time: 200, Hash: 1}, {Time: 50, Hash: 1}}); !errors.Is(err, ErrFncUnsorted) {
+		t.Errorf("earlier time: %v", err)
+	}
+}
+
+func TestWriteFncFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, `track.fnc`)
+
+	f := testFnc()
+	if err := WriteFncFile(path, f); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm != 0644 {
+		t.Errorf("file mode %o, expected 644", perm)
+	}
+	if got, err := ReadFncFile(path); (err != nil) || (len(got.Hashes) != len(f.Hashes)) {
+		t.Errorf("read back: %v", err)
+	}
+
+	// неудачная запись не оставляет временных файлов и не трогает уже записанный
+	bad := &FncFile{FncHeader: FncHeader{SampleRate: -1}}
+	if err := WriteFncFile(path, bad); !errors.Is(err, ErrWrongParams) {
+		t.Errorf("bad header: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (len(entries) != 1) || (entries[0].Name() != `track.fnc`) {
+		for _, e := range entries {
+			t.Logf("left: %s", e.Name())
+		}
+		t.Errorf("%d files in directory after failed write, expected 1", len(entries))
+	}
+	if got, err := ReadFncFile(path); (err != nil) || (len(got.Hashes) != len(f.Hashes)) {
+		t.Errorf("file is damaged by failed write: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+//go:build !cgo || purego
+
+package fennec
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+const (
+	// число семплов (на канал) в MPEG-1 Layer III фрейме; у MPEG-2/2.5 (частота ниже 32000) фрейм из одной гранулы - вдвое меньше
+	gomp3FrameSamples = 1152
+	// частота, ниже которой поток считается MPEG-2/2.5
+	gomp3MinMpeg1Rate = 32000
+	// go-mp3 всегда отдает 16-битное стерео
+	gomp3BytesPerSample = 4
+)
+
+type (
+	// mp3Reader декодер MP3 на чистом Go (без cgo и libmad), выбирается тегом сборки purego или при CGO_ENABLED=0
+	mp3Reader struct {
+		fd *os.File
+
+		dec   *mp3.Decoder
+		frame []byte
+
+		left, right []int16
+		conv        pcmConverter
+	}
+)
+
+func NewMP3Reader(path string, sampleRate int, bits int) (*mp3Reader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := NewMP3ReaderFrom(fd, sampleRate, bits)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	rd.fd = fd
+
+	return rd, nil
+}
+
+// NewMP3ReaderFrom создает декодер, читающий MP3 из r
+func NewMP3ReaderFrom(r io.Reader, sampleRate int, bits int) (*mp3Reader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	}
+
+	dec, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, decodeError{err}
+	}
+
+	// go-mp3 декодирует целыми фреймами, поэтому чтение ровно по фрейму дает те же границы, что у libmad,
+	//   и эвристика зеркальных каналов в downmix срабатывает на тех же кусках
+	frameSamples := gomp3FrameSamples
+	if dec.SampleRate() < gomp3MinMpeg1Rate {
+		frameSamples /= 2
+	}
+
+	rd := &mp3Reader{
+		dec:   dec,
+		frame: make([]byte, frameSamples*gomp3BytesPerSample),
+		conv:  pcmConverter{sampleRate: sampleRate},
+	}
+
+	return rd, nil
+}
+
+func (mp3r *mp3Reader) Close() error {
+	if mp3r.dec == nil {
+		return ErrWrongParams
+	}
+	mp3r.dec = nil
+
+	if mp3r.fd != nil {
+		mp3r.fd.Close()
+		mp3r.fd = nil
+	}
+
+	return nil
+}
+
+// ReadFrame читает один PCM фрейм из файла
+// buf используется как буфер под ответ, чтобы не выделять память при каждом вызове.
+// На случай расширения буфера (если размера не хватило) функция возвращает новый буфер (или тот же).
+func (mp3r *mp3Reader) ReadFrame(buf []int16) ([]int16, error) {
+	n, err := io.ReadFull(mp3r.dec, mp3r.frame)
+	if (err == io.ErrUnexpectedEOF) || (err == io.EOF) {
+		if n < gomp3BytesPerSample {
+			return mp3r.conv.finish(buf)
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, decodeError{err}
+	}
+
+	frame := mp3r.frame[:n-n%gomp3BytesPerSample]
+	samplesCnt := len(frame) / gomp3BytesPerSample
+
+	mp3r.left, mp3r.right = mp3r.left[0:0], mp3r.right[0:0]
+	for i := 0; i < samplesCnt; i++ {
+		smpl := frame[i*gomp3BytesPerSample:]
+		mp3r.left = append(mp3r.left, int16(binary.LittleEndian.Uint16(smpl[0:2])))
+		mp3r.right = append(mp3r.right, int16(binary.LittleEndian.Uint16(smpl[2:4])))
+	}
+
+	return mp3r.conv.convert(buf, mp3r.left, mp3r.right, mp3r.dec.SampleRate()), nil
+}
@@ -0,0 +1,232 @@
+package fennec
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+type (
+	TrackID uint32
+
+	// posting одно вхождение хеша в трек каталога
+	posting struct {
+		Track TrackID
+		Time  uint32
+	}
+
+	// Index инвертированный индекс хешей: Hash.Hash -> (трек, Hash.Time).
+	// Позволяет искать фрагмент сразу по всему каталогу без попарного сравнения с каждым треком.
+	// Все хеши индекса должны быть одной версии параметров (см. AddFingerprint, QueryFingerprint).
+	// Безопасен для конкурентного использования.
+	Index struct {
+		matcher *Matcher
+
+		mu       sync.RWMutex
+		postings map[uint32][]posting
+		lens     map[TrackID]int
+	}
+
+	// QueryResult результат поиска по индексу. Трек каталога выступает как songA, запрос как songB,
+	// так что Offset это позиция начала запроса внутри трека.
+	QueryResult struct {
+		TrackID TrackID
+		MatchResult
+	}
+
+	// QueryResults сортирует по убыванию Score, а при равных - по возрастанию TrackID
+	QueryResults []QueryResult
+)
+
+func (r QueryResults) Len() int      { return len(r) }
+func (r QueryResults) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r QueryResults) Less(i, j int) bool {
+	if r[i].Score != r[j].Score {
+		return r[i].Score > r[j].Score
+	}
+	return r[i].TrackID < r[j].TrackID
+}
+
+// NewIndex создает индекс для отпечатков, построенных с DefaultConfig
+func NewIndex() *Index {
+	return newIndex(NewMatcher())
+}
+
+// NewIndexWithConfig создает индекс для отпечатков, построенных с конфигурацией cfg
+func NewIndexWithConfig(cfg FingerprintConfig) (*Index, error) {
+	m, err := NewMatcherWithConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newIndex(m), nil
+}
+
+func newIndex(m *Matcher) *Index {
+	return &Index{
+		matcher:  m,
+		postings: make(map[uint32][]posting),
+		lens:     make(map[TrackID]int),
+	}
+}
+
+// Version версия параметров отпечатков, хранимых в индексе
+func (idx *Index) Version() FingerprintVersion {
+	return idx.matcher.version
+}
+
+// Add добавляет хеши трека в индекс. Повторное добавление того же trackID дописывает хеши к уже имеющимся.
+func (idx *Index) Add(trackID TrackID, hashes Hashes) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, h := range hashes {
+		if pp := idx.matcher.layout.decode(h); pp.Bin1 == 0 || pp.Bin2 == 0 {
+			// хеши с очень низкими частотами пропускаем. малослышимый шум
+			continue
+		}
+		idx.postings[h.Hash] = append(idx.postings[h.Hash], posting{Track: trackID, Time: h.Time})
+	}
+
+	idx.lens[trackID] += len(hashes)
+}
+
+// AddFingerprint аналог Add, проверяющий версию отпечатка (ErrVersionMismatch)
+func (idx *Index) AddFingerprint(trackID TrackID, fp Fingerprint) error {
+	if err := checkVersion(idx.matcher.version, fp.Version); err != nil {
+		return err
+	}
+
+	idx.Add(trackID, fp.Hashes)
+	return nil
+}
+
+// Remove удаляет трек из индекса
+func (idx *Index) Remove(trackID TrackID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.lens[trackID]; !ok {
+		return
+	}
+
+	for hash, postings := range idx.postings {
+		kept := postings[:0]
+		for _, p := range postings {
+			if p.Track != trackID {
+				kept = append(kept, p)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(idx.postings, hash)
+		} else {
+			idx.postings[hash] = kept
+		}
+	}
+
+	delete(idx.lens, trackID)
+}
+
+func (idx *Index) maxTrackID() TrackID {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var maxID TrackID
+	for id := range idx.lens {
+		if id > maxID {
+			maxID = id
+		}
+	}
+	return maxID
+}
+
+// Len возвращает число треков в индексе
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.lens)
+}
+
+// Query ищет в индексе треки, содержащие фрагмент hashes, и возвращает topK лучших (topK <= 0 - все найденные)
+func (idx *Index) Query(hashes Hashes, topK int) []QueryResult {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return queryPostings(idx.matcher, hashes, topK, idx.lookup, idx.trackLen)
+}
+
+// QueryFingerprint аналог Query, проверяющий версию отпечатка (ErrVersionMismatch)
+func (idx *Index) QueryFingerprint(fp Fingerprint, topK int) ([]QueryResult, error) {
+	if err := checkVersion(idx.matcher.version, fp.Version); err != nil {
+		return nil, err
+	}
+
+	return idx.Query(fp.Hashes, topK), nil
+}
+
+func (idx *Index) lookup(hash uint32, fn func(p posting)) {
+	for _, p := range idx.postings[hash] {
+		fn(p)
+	}
+}
+
+func (idx *Index) trackLen(trackID TrackID) int {
+	return idx.lens[trackID]
+}
+
+// queryPostings собирает совпадения запроса с постингами каждого трека
+// и ранжирует треки тем же голосованием по смещениям, что и Matcher.Match.
+// В отличие от Match, запрос - фрагмент из произвольного места трека, поэтому смещение не ограничено и не штрафуется.
+func queryPostings(
+	m *Matcher, hashes Hashes, topK int,
+	lookup func(hash uint32, fn func(p posting)), trackLen func(trackID TrackID) int,
+) []QueryResult {
+	matches := make(map[TrackID][]hashMatch)
+
+	for _, q := range hashes {
+		qPP := m.layout.decode(q)
+		if qPP.Bin1 == 0 || qPP.Bin2 == 0 {
+			// хеши с очень низкими частотами пропускаем. малослышимый шум
+			continue
+		}
+
+		from := q.Hash - uint32(minInt(int(q.Hash), hashesDistortion))
+		for hash := from; hash <= q.Hash+hashesDistortion; hash++ {
+			diffA := uint32(m.layout.decode(Hash{Hash: hash}).TimeDiff)
+
+			lookup(hash, func(p posting) {
+				match := hashMatch{
+					timeA: p.Time, diffA: diffA,
+					timeB: q.Time, diffB: uint32(qPP.TimeDiff),
+				}
+				if scaleAllowed(match) {
+					matches[p.Track] = append(matches[p.Track], match)
+				}
+			})
+		}
+	}
+
+	var results QueryResults
+	for trackID, trackMatches := range matches {
+		if len(trackMatches) < minAllowedCnt {
+			continue
+		}
+
+		res := m.offsetFromMatches(trackMatches, math.MaxInt32)
+		res.LenA, res.LenB = trackLen(trackID), len(hashes)
+		m.scoreClipResult(&res)
+
+		if res.Score > 0 {
+			results = append(results, QueryResult{TrackID: trackID, MatchResult: res})
+		}
+	}
+
+	sort.Sort(results)
+
+	if (topK > 0) && (len(results) > topK) {
+		results = results[:topK]
+	}
+
+	return results
+}
@@ -0,0 +1,67 @@
+package fennec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testTrackHashes синтезирует хеши трека длиной sec секунд (примерно perSec хешей в секунду)
+func testTrackHashes(sec int, perSec int, seed int64) Hashes {
+	r := rand.New(rand.NewSource(seed))
+
+	maxTime := int(float64(sec) * DefaultConfig.HashColsInOneSec())
+	hashes := make(Hashes, 0, sec*perSec)
+	for i := 0; i < sec*perSec; i++ {
+		bin1 := uint(10 + r.Intn(900))
+		pp := PeakPair{
+			Time1:    uint(r.Intn(maxTime)),
+			Bin1:     bin1,
+			Bin2:     bin1 + uint(r.Intn(20)),
+			TimeDiff: uint(3 + r.Intn(55)),
+		}
+		hashes = append(hashes, Hash{Time: uint32(pp.Time1), Hash: pp.ToHash()})
+	}
+	return hashes
+}
+
+// testClip вырезает из хешей трека фрагмент [fromSec, fromSec+sec) со временем от начала фрагмента
+func testClip(hashes Hashes, fromSec, sec float64) Hashes {
+	colsInOneSec := DefaultConfig.HashColsInOneSec()
+	from, to := uint32(fromSec*colsInOneSec), uint32((fromSec+sec)*colsInOneSec)
+
+	var clip Hashes
+	for _, h := range hashes {
+		if (h.Time >= from) && (h.Time < to) {
+			clip = append(clip, Hash{Time: h.Time - from, Hash: h.Hash})
+		}
+	}
+	return clip
+}
+
+func TestIndexQueryFarIntoTrack(t *testing.T) {
+	idx := NewIndex()
+	for id := TrackID(1); id <= 20; id++ {
+		idx.Add(id, testTrackHashes(15*60, 20, int64(id)))
+	}
+	track := testTrackHashes(15*60, 20, 7)
+
+	for _, fromSec := range []float64{30, 5*60 + 30, 7 * 60, 11*60 + 15, 14 * 60} {
+		results := idx.Query(testClip(track, fromSec, 15), 3)
+		if len(results) == 0 {
+			t.Errorf("clip from %.0f sec: not found", fromSec)
+			continue
+		}
+
+		res := results[0]
+		if res.TrackID != 7 {
+			t.Errorf("clip from %.0f sec: found track %d", fromSec, res.TrackID)
+		}
+		if math.Abs(res.OffsetInSec-fromSec) > 0.5 {
+			t.Errorf("clip from %.0f sec: offset %.2f sec", fromSec, res.OffsetInSec)
+		}
+		if (res.ScoreK != 1) || (res.Similarity < DefaultMonitorConfig.MinSimilarity) {
+			t.Errorf("clip from %.0f sec: %s", fromSec, res.MatchResult)
+		}
+	}
+}
@@ -1,3 +1,5 @@
+//go:build cgo && !purego
+
 package fennec
 
 /*
@@ -51,30 +53,28 @@ int my_mad_frame_decode(mad_mp3reader *r) {
 import "C"
 
 import (
-	"errors"
 	"io"
-	"math"
 	"os"
 	"unsafe"
 )
 
-var (
-	ErrWrongParams = errors.New(`Wrong params`)
-	ErrMmapFail    = errors.New(`mmap fail`)
-)
-
 type (
+	// mp3Reader декодер MP3 на основе libmad
 	mp3Reader struct {
 		fd   *os.File
 		size int64
 
-		sampleRate int
-
 		reader    *C.mad_mp3reader
 		mmap      unsafe.Pointer
 		lastFrame []byte
 
-		accumBuf []int16
+		// чтение из io.Reader: данные порциями подгружаются в буфер streamBuf (выделен в C)
+		src       io.Reader
+		streamBuf unsafe.Pointer
+		srcEOF    bool
+
+		left, right []int16
+		conv        pcmConverter
 	}
 
 	errMadUnrecover struct {
@@ -102,19 +102,22 @@ func madScale(smpl C.mad_fixed_t) int16 {
 	return int16(sample)
 }
 
-func mergeChannels(ch1, ch2 int16) int16 {
-	return int16((int32(ch1) + int32(ch2)) >> 1)
-}
+const (
+	// размер порции, которой данные из io.Reader подгружаются в mad_stream
+	madStreamChunkSize = 64 * 1024
+)
 
 func (err errMadUnrecover) Error() string {
 	return `Unecoverable mad decoding error: ` + err.err
 }
 
+func (err errMadUnrecover) Is(target error) bool {
+	return target == ErrDecode
+}
+
 func NewMP3Reader(path string, sampleRate int, bits int) (*mp3Reader, error) {
-	if bits != 16 {
-		return nil, ErrWrongParams
-	} else if (sampleRate < 11025) || (sampleRate%11025 != 0) { // пример без интерполирования
-		return nil, ErrWrongParams
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
 	}
 
 	fi, err := os.Stat(path)
@@ -128,26 +131,59 @@ func NewMP3Reader(path string, sampleRate int, bits int) (*mp3Reader, error) {
 	}
 
 	rd := &mp3Reader{
-		fd:         fd,
-		size:       fi.Size(),
-		sampleRate: sampleRate,
+		fd:   fd,
+		size: fi.Size(),
+		conv: pcmConverter{sampleRate: sampleRate},
 	}
 
-	rd.openMp3()
+	if err := rd.openMp3(); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+// NewMP3ReaderFrom создает декодер, читающий MP3 из r порциями (без временных файлов и mmap)
+func NewMP3ReaderFrom(r io.Reader, sampleRate int, bits int) (*mp3Reader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	}
+
+	rd := &mp3Reader{
+		src:       r,
+		streamBuf: C.malloc(C.size_t(madStreamChunkSize + C.MAD_BUFFER_GUARD)),
+		conv:      pcmConverter{sampleRate: sampleRate},
+	}
+
+	rd.reader = C.my_mad_open_reader(nil, 0)
+
+	if err := rd.refill(); (err != nil) && (err != io.EOF) {
+		rd.Close()
+		return nil, err
+	}
 
 	return rd, nil
 }
 
 func (mp3r *mp3Reader) Close() error {
-	if mp3r.fd == nil {
+	if mp3r.reader == nil {
 		return ErrWrongParams
 	}
-	mp3r.fd.Close()
-	mp3r.fd = nil
 
 	C.my_mad_close_reader(mp3r.reader)
+	mp3r.reader = nil
 
-	C.munmap(mp3r.mmap, C.size_t(mp3r.size))
+	if mp3r.fd != nil {
+		C.munmap(mp3r.mmap, C.size_t(mp3r.size))
+		mp3r.fd.Close()
+		mp3r.fd = nil
+	}
+
+	if mp3r.streamBuf != nil {
+		C.free(mp3r.streamBuf)
+		mp3r.streamBuf = nil
+	}
 
 	if mp3r.lastFrame != nil {
 		mp3r.lastFrame = nil
@@ -156,22 +192,71 @@ func (mp3r *mp3Reader) Close() error {
 	return nil
 }
 
+// refill переносит недекодированный остаток в начало streamBuf и дочитывает следующую порцию из src.
+// На конце потока добавляет MAD_BUFFER_GUARD нулей, чтобы libmad смог декодировать последний фрейм.
+func (mp3r *mp3Reader) refill() error {
+	if mp3r.srcEOF {
+		return io.EOF
+	}
+
+	stream := &mp3r.reader.madStream
+
+	remaining := 0
+	if stream.buffer != nil {
+		remaining = int(C.calcRemainInMadStream(stream))
+		C.memmove(mp3r.streamBuf, unsafe.Pointer(stream.next_frame), C.size_t(remaining))
+	}
+
+	buf := unsafe.Slice((*byte)(mp3r.streamBuf), madStreamChunkSize+C.MAD_BUFFER_GUARD)
+
+	n, err := io.ReadFull(mp3r.src, buf[remaining:madStreamChunkSize])
+	if (err == io.EOF) || (err == io.ErrUnexpectedEOF) {
+		mp3r.srcEOF = true
+		for i := 0; i < C.MAD_BUFFER_GUARD; i++ {
+			buf[remaining+n+i] = 0
+		}
+		n += C.MAD_BUFFER_GUARD
+	} else if err != nil {
+		return err
+	}
+
+	C.mad_stream_buffer(stream, (*C.uchar)(mp3r.streamBuf), C.ulong(remaining+n))
+
+	return nil
+}
+
 // ReadFrame читает один PCM фрейм из файла
 // buf используется как буфер под ответ, чтобы не выделять память при каждом вызове.
 // На случай расширения буфера (если размера не хватило) функция возвращает новый буфер (или тот же).
 func (mp3r *mp3Reader) ReadFrame(buf []int16) ([]int16, error) {
+	frame, err := mp3r.decodeFrame(buf)
+	if err == io.EOF {
+		return mp3r.conv.finish(buf)
+	}
+	return frame, err
+}
+
+func (mp3r *mp3Reader) decodeFrame(buf []int16) ([]int16, error) {
 	stream := &mp3r.reader.madStream
 	frame := &mp3r.reader.madFrame
 
 	for {
 		if C.mad_frame_decode(frame, stream) != 0 {
 			if C.my_mad_recoverable(C.int(stream.error)) != 0 {
+				// ошибка в дописанном хвосте означает конец файла; в потоке же (в т.ч. после srcEOF)
+				//   libmad сам пропускает мусор вроде ID3 тегов, и декодирование продолжается до MAD_ERROR_BUFLEN
 				if mp3r.lastFrame != nil {
 					break
-				} else {
-					continue
 				}
+				continue
 			} else if stream.error == C.MAD_ERROR_BUFLEN {
+				if mp3r.src != nil {
+					if err := mp3r.refill(); err != nil {
+						return nil, err
+					}
+					continue
+				}
+
 				if mp3r.lastFrame != nil {
 					return nil, io.EOF
 				}
@@ -194,15 +279,15 @@ func (mp3r *mp3Reader) ReadFrame(buf []int16) ([]int16, error) {
 		}
 
 		C.mad_synth_frame(&mp3r.reader.madSynth, frame)
-		return mp3r.buildCurrentFrame(buf, false), nil
+		return mp3r.buildCurrentFrame(buf), nil
 	}
 
 	return nil, io.EOF
 }
 
 func (mp3r *mp3Reader) openMp3() error {
-	mp3r.mmap = C.mmap(unsafe.Pointer(uintptr(0)), C.size_t(mp3r.size), C.PROT_READ, C.MAP_PRIVATE, C.int(mp3r.fd.Fd()), C.off_t(0))
-	if mp3r.mmap == nil {
+	mp3r.mmap = C.mmap(nil, C.size_t(mp3r.size), C.PROT_READ, C.MAP_PRIVATE, C.int(mp3r.fd.Fd()), C.off_t(0))
+	if (mp3r.mmap == nil) || (mp3r.mmap == C.MAP_FAILED) {
 		return ErrMmapFail
 	}
 
@@ -211,73 +296,24 @@ func (mp3r *mp3Reader) openMp3() error {
 	return nil
 }
 
-func (mp3r *mp3Reader) buildCurrentFrame(buf []int16, forceMono bool) []int16 {
+func (mp3r *mp3Reader) buildCurrentFrame(buf []int16) []int16 {
 	pcm := &(mp3r.reader.madSynth.pcm)
 
-	srcSampleRate := int(pcm.samplerate)
 	srcSamplesCnt := int(pcm.length)
-	srcChannelsCnt := int(pcm.channels)
-
-	leftCh := pcm.samples[0]
-	leftChIdx := 0
-	rightCh := pcm.samples[1]
-	rightChIdx := 0
-
-	buf = buf[0:0]
-
-	sampleRateKoeff := srcSampleRate / mp3r.sampleRate
-	if cap(mp3r.accumBuf) < sampleRateKoeff {
-		mp3r.accumBuf = make([]int16, sampleRateKoeff)
-	}
-
-	var (
-		mixAvg, leftAvg, rightAvg int64
-	)
-
-	accumIdx := 0
-	for sampleIdx := 0; sampleIdx < srcSamplesCnt; sampleIdx++ {
-		sample := madScale(leftCh[leftChIdx])
-		leftChIdx++
-		leftAvg += int64(sample)
-
-		if !forceMono && (srcChannelsCnt == 2) {
-			sample2 := madScale(rightCh[rightChIdx])
-			rightChIdx++
-			rightAvg += int64(sample2)
-			sample = mergeChannels(sample, sample2)
-		}
-
-		if sampleRateKoeff > 1 {
-			mp3r.accumBuf[accumIdx] = sample
-			if accumIdx++; accumIdx == sampleRateKoeff {
-				accumIdx = 0
 
-				sampleAccum := 0
-				for i := 0; i < sampleRateKoeff; i++ {
-					sampleAccum += int(mp3r.accumBuf[i])
-				}
-				sample = int16(sampleAccum / sampleRateKoeff)
-			} else {
-				continue
-			}
-		}
-
-		buf = append(buf, sample)
-
-		mixAvg += int64(sample)
+	mp3r.left = mp3r.left[0:0]
+	for _, smpl := range pcm.samples[0][:srcSamplesCnt] {
+		mp3r.left = append(mp3r.left, madScale(smpl))
 	}
 
-	if !forceMono && (len(buf) > 0) {
-		if avg := math.Abs(float64(mixAvg) / float64(len(buf))); avg < 1 {
-			// Если среднее значение вышло ни то ни се, а отдельные L/R каналы почти что зеркально противоположны,
-			//   то просто берем в качестве результа просто один из каналов (повторный проход по фрейму).
-			avgL := float64(leftAvg) / float64(srcSamplesCnt)
-			avgR := float64(rightAvg) / float64(srcSamplesCnt)
-			if (math.Abs(avgL+avgR) < 1) && (math.Abs(avgL) >= 1) {
-				return mp3r.buildCurrentFrame(buf, true)
-			}
+	right := []int16(nil)
+	if pcm.channels == 2 {
+		mp3r.right = mp3r.right[0:0]
+		for _, smpl := range pcm.samples[1][:srcSamplesCnt] {
+			mp3r.right = append(mp3r.right, madScale(smpl))
 		}
+		right = mp3r.right
 	}
 
-	return buf
+	return mp3r.conv.convert(buf, mp3r.left, right, int(pcm.samplerate))
 }
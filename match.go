@@ -11,10 +11,81 @@ type (
 	Matcher struct {
 		gaus Gaussian
 
-		poolInts    sync.Pool
-		poolValIdxs sync.Pool
+		// раскладка хешей и число колонок в секунде, с которыми строились сравниваемые отпечатки
+		layout       hashLayout
+		colsInOneSec float64
+		version      FingerprintVersion
+
+		// штраф за смещение (гауссиана по секундам), считается один раз в NewMatcher,
+		// чтобы Match можно было вызывать конкурентно
+		offsetPenalty []float64
+
+		// буферы, переиспользуемые между сравнениями (в т.ч. конкурентными, см. MatchMany)
 		poolFloat64 sync.Pool
 		poolHashes  sync.Pool
+		poolMatches sync.Pool
+		poolOffs    sync.Pool
+	}
+
+	// hashMatch пара совпавших хешей двух треков (в исходной ориентации songA/songB)
+	hashMatch struct {
+		timeA, timeB uint32
+		diffA, diffB uint32
+	}
+
+	// MatchResult результат сравнения двух треков
+	MatchResult struct {
+		// Итоговая оценка совпадения (CntInOffset с учетом штрафа за смещение)
+		Score float64
+		// Нормированная похожесть 0..1
+		Similarity float64
+
+		// Смещение songA относительно songB в колонках спектрограммы и в секундах
+		Offset      int
+		OffsetInSec float64
+		// Масштаб времени songA относительно songB (1 - без масштабирования)
+		Scale float64
+
+		// Число совпавших хешей в окрестности оптимального смещения
+		CntInOffset int
+		// Покрытие: CntInOffset в процентах от длины более короткого трека
+		CntInOffsetPerc float64
+		// Сумма и число смещений, набравших хотя бы minAllowedCnt совпадений
+		SumOffs int
+		CntOffs int
+		// Общее число совпавших пар хешей (до голосования по смещениям)
+		MatchedHashes int
+
+		LenA int
+		LenB int
+
+		// Штраф за величину смещения (0..1)
+		ScoreK float64
+
+		// Интервалы в songA и songB, покрытые хешами в окрестности оптимального смещения
+		RegionA TimeSpan
+		RegionB TimeSpan
+		// Density[i] - число совпавших хешей в i-й секунде RegionA (пропуски внутри совпадения дают нули)
+		Density []int
+	}
+
+	// TimeSpan интервал времени трека в секундах
+	TimeSpan struct {
+		Start, End float64
+	}
+
+	// driftPoint доминирующее смещение timeA - timeB в одном окне songB (средние по совпадениям окна)
+	driftPoint struct {
+		timeB float64
+		drift float64
+		cnt   int
+	}
+
+	// offsetVotes гистограмма смещений для одного масштаба
+	offsetVotes struct {
+		offs        map[int32]int32
+		offset      int
+		cntInOffset int
 	}
 )
 
@@ -33,17 +104,35 @@ const (
 
 	// величина полуокресности отпимальной точки смещения, в пределах которой считаем все еще совпавшим
 	offsetDistortion = 7
+
+	// длина окна (по songB), в котором ищется доминирующее смещение при оценке масштаба по дрейфу
+	scaleWindowSec = 5
+	// ширина корзины гистограммы смещений внутри окна
+	scaleBinCols = 2 * offsetDistortion
+	// сколько самых сильных окон участвует в оценке наклона дрейфа
+	maxDriftPoints = 64
+	// насколько МНК уточнение может отойти от оценки Theil-Sen
+	scaleStep = 0.01
 )
 
+// NewMatcher создает Matcher для отпечатков, построенных с DefaultConfig
 func NewMatcher() *Matcher {
-	m := Matcher{}
+	return newMatcher(DefaultConfig)
+}
 
-	m.poolInts.New = func() interface{} {
-		return []int{}
+// NewMatcherWithConfig создает Matcher для отпечатков, построенных с конфигурацией cfg
+func NewMatcherWithConfig(cfg FingerprintConfig) (*Matcher, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
+	return newMatcher(cfg), nil
+}
 
-	m.poolValIdxs.New = func() interface{} {
-		return ValIdxs{}
+func newMatcher(cfg FingerprintConfig) *Matcher {
+	m := Matcher{
+		layout:       cfg.layout(),
+		colsInOneSec: cfg.HashColsInOneSec(),
+		version:      cfg.Version(),
 	}
 
 	m.poolFloat64.New = func() interface{} {
@@ -54,20 +143,24 @@ func NewMatcher() *Matcher {
 		return Hashes{}
 	}
 
+	m.poolMatches.New = func() interface{} {
+		return []hashMatch{}
+	}
+
+	m.poolOffs.New = func() interface{} {
+		return make(map[int32]int32)
+	}
+
+	maxOffsetInSec := maxTimeMsDiffForTracksCompare / 1000
+	m.offsetPenalty = m.gaus.Make(maxOffsetInSec, float64(maxOffsetInSec)/3)
+
 	return &m
 }
 
-func (m Matcher) findOptimalOffset(songA Hashes, songB Hashes) (
-	offset int, cntInOffset int, sumOffs int, cntOffs int,
-) {
-	// предварительная проверка на отсортированность ускоряет повторное использование, но замедляет первоначальное.
-	// не факт, что этот код останется в будущем. пока лишь тесты.
-	if !sort.IsSorted(songA) {
-		sort.Sort(songA)
-	}
-	if !sort.IsSorted(songB) {
-		sort.Sort(songB)
-	}
+// collectMatches сливает два подготовленных (см. Prepare) списка хешей и возвращает все пары совпадений,
+// у которых отношение TimeDiff укладывается в допустимое масштабирование. Результат дописывается в buf[:0].
+func (m *Matcher) collectMatches(songA Hashes, songB Hashes, buf []hashMatch) (matches []hashMatch) {
+	matches = buf[:0]
 
 	swapped := false
 	if len(songA) < len(songB) {
@@ -77,24 +170,9 @@ func (m Matcher) findOptimalOffset(songA Hashes, songB Hashes) (
 
 	bLen := len(songB)
 
-	offs := make(map[int32]int32)
-
-	hashColsInOneSec := HashColsInOneSec()
-	offsetInCols := int32(math.Ceil(float64(maxTimeMsDiffForTracksCompare) / 1000 * hashColsInOneSec))
-
-	songAMaxTime, songBMaxTime := uint(0), uint(0)
-
 	bpFrom := 0
 	for _, a := range songA {
-		aPP := a.ToPeakPair()
-		if aPP.Bin1 == 0 || aPP.Bin2 == 0 {
-			// хеши с очень низкими частотами пропускаем. малослышимый шум
-			continue
-		}
-
-		if songAMaxTime < aPP.Time2() {
-			songAMaxTime = aPP.Time2()
-		}
+		aPP := m.layout.decode(a)
 
 		for (bpFrom < bLen) && (a.Hash > (songB[bpFrom].Hash + uint32(hashesDistortion))) {
 			bpFrom++
@@ -108,105 +186,457 @@ func (m Matcher) findOptimalOffset(songA Hashes, songB Hashes) (
 
 		for bp := bpFrom; (bp < bLen) && (absInt(int(songB[bp].Hash)-int(a.Hash)) <= hashesDistortion); bp++ {
 			b := songB[bp]
-			bPP := b.ToPeakPair()
-			if bPP.Bin1 == 0 || bPP.Bin2 == 0 {
-				// хеши с очень низкими частотами пропускаем. малослышимый шум
-				continue
+			bPP := m.layout.decode(b)
+
+			match := hashMatch{
+				timeA: a.Time, diffA: uint32(aPP.TimeDiff),
+				timeB: b.Time, diffB: uint32(bPP.TimeDiff),
+			}
+			if swapped {
+				match = hashMatch{
+					timeA: b.Time, diffA: uint32(bPP.TimeDiff),
+					timeB: a.Time, diffB: uint32(aPP.TimeDiff),
+				}
 			}
 
-			if songBMaxTime < bPP.Time2() {
-				songBMaxTime = bPP.Time2()
+			if !scaleAllowed(match) {
+				continue
 			}
 
-			stampA := aPP.TimeDiff
-			stampB := bPP.TimeDiff
+			matches = append(matches, match)
+		}
+	}
+
+	return
+}
 
-			stampDiff := float64(stampA) - float64(stampB)
+// scaleAllowed проверяет, что пара может совпасть хоть при каком-то допустимом масштабе
+func scaleAllowed(match hashMatch) bool {
+	stampA, stampB := float64(match.diffA), float64(match.diffB)
 
-			if math.Abs(stampDiff) >= timeDistortion {
-				continue
+	if math.Abs(stampA-stampB) < timeDistortion {
+		return true
+	} else if stampB == 0 {
+		return false
+	}
+
+	return math.Abs(stampA/stampB-1) <= ScaleAllowedDiff
+}
+
+// estimateScales выбирает кандидатов на масштаб songA относительно songB. Первым кандидатом всегда идет 1.
+//
+// При масштабе s хеш с временем timeB в songB оказывается в songA на s*timeB + offset, т.е. смещение
+// timeA - timeB дрейфует вдоль songB с наклоном s-1. Совпадения делятся на окна по timeB, в каждом окне
+// берется доминирующее смещение, наклон по этим точкам оценивается медианой попарных наклонов (Theil-Sen),
+// устойчивой к окнам, где победил шум, и уточняется МНК по совпадениям вдоль найденной прямой.
+func (m *Matcher) estimateScales(matches []hashMatch) []float64 {
+	scales := []float64{1}
+
+	points := m.driftPoints(matches)
+	if len(points) < 2 {
+		return scales
+	}
+
+	slope, ok := m.driftSlope(points)
+	if !ok || (math.Abs(slope) < ScaleEpsilon) {
+		return scales
+	}
+
+	// Theil-Sen: точка пересечения - медиана остатков
+	intercepts := m.poolFloat64.Get().([]float64)[:0]
+	for _, p := range points {
+		intercepts = append(intercepts, p.drift-slope*p.timeB)
+	}
+	intercept := median(intercepts)
+	m.poolFloat64.Put(intercepts[:0])
+
+	scale := 1 + slope
+	if refined, ok := fitScale(matches, slope, intercept); ok && (math.Abs(refined-scale) < scaleStep) {
+		scale = refined
+	}
+
+	if (math.Abs(scale-1) >= ScaleEpsilon) && (math.Abs(scale-1) <= ScaleAllowedDiff) {
+		scales = append(scales, scale)
+	}
+
+	return scales
+}
+
+// driftPoints для каждого окна timeB длиной scaleWindowSec находит доминирующее смещение timeA - timeB
+// (окна, где оно набрало меньше minAllowedCnt голосов, пропускаются). Остаются maxDriftPoints самых сильных окон.
+func (m *Matcher) driftPoints(matches []hashMatch) []driftPoint {
+	if len(matches) < 2*minAllowedCnt {
+		return nil
+	}
+
+	sorted := append(m.poolMatches.Get().([]hashMatch)[:0], matches...)
+	defer func() { m.poolMatches.Put(sorted[:0]) }()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].timeB < sorted[j].timeB })
+
+	windowCols := uint32(math.Max(1, math.Round(scaleWindowSec*m.colsInOneSec)))
+
+	bins := m.poolOffs.Get().(map[int32]int32)
+	defer func() {
+		for k := range bins {
+			delete(bins, k)
+		}
+		m.poolOffs.Put(bins)
+	}()
+
+	var points []driftPoint
+	for from := 0; from < len(sorted); {
+		windowEnd := sorted[from].timeB - sorted[from].timeB%windowCols + windowCols
+		to := from
+		for (to < len(sorted)) && (sorted[to].timeB < windowEnd) {
+			to++
+		}
+		window := sorted[from:to]
+		from = to
+
+		if len(window) < minAllowedCnt {
+			continue
+		}
+
+		for k := range bins {
+			delete(bins, k)
+		}
+		bestBin, bestCnt := int32(0), int32(0)
+		for _, match := range window {
+			bin := driftBin(match)
+			n := bins[bin] + 1
+			bins[bin] = n
+			if n > bestCnt {
+				bestBin, bestCnt = bin, n
 			}
+		}
+		if bestCnt < minAllowedCnt {
+			continue
+		}
 
-			tDiff := int32(a.Time) - int32(b.Time)
+		// соседние корзины тоже учитываются, чтобы не терять смещение, попавшее на границу корзин
+		var p driftPoint
+		for _, match := range window {
+			if bin := driftBin(match); (bin >= bestBin-1) && (bin <= bestBin+1) {
+				p.timeB += float64(match.timeB)
+				p.drift += float64(int64(match.timeA) - int64(match.timeB))
+				p.cnt++
+			}
+		}
+		p.timeB /= float64(p.cnt)
+		p.drift /= float64(p.cnt)
 
-			if (tDiff < -offsetInCols) || (tDiff > offsetInCols) {
+		points = append(points, p)
+	}
+
+	if len(points) > maxDriftPoints {
+		sort.Slice(points, func(i, j int) bool { return points[i].cnt > points[j].cnt })
+		points = points[:maxDriftPoints]
+	}
+
+	return points
+}
+
+// driftSlope медиана наклонов между парами точек, разнесенных хотя бы на половину окна.
+// Наклоны больше ScaleAllowedDiff (точки разных вхождений или шум) не учитываются.
+func (m *Matcher) driftSlope(points []driftPoint) (float64, bool) {
+	minDist := scaleWindowSec * m.colsInOneSec / 2
+
+	slopes := m.poolFloat64.Get().([]float64)[:0]
+	defer func() { m.poolFloat64.Put(slopes[:0]) }()
+
+	for i := range points {
+		for j := range points {
+			dt := points[j].timeB - points[i].timeB
+			if dt < minDist {
 				continue
 			}
+			if slope := (points[j].drift - points[i].drift) / dt; math.Abs(slope) <= ScaleAllowedDiff {
+				slopes = append(slopes, slope)
+			}
+		}
+	}
 
-			if n, ok := offs[tDiff]; !ok {
-				offs[tDiff] = 1
-			} else {
-				if n++; int(n) > cntInOffset {
-					cntInOffset = int(n)
-					offset = int(tDiff)
-				}
-				offs[tDiff] = n
+	if len(slopes) == 0 {
+		return 0, false
+	}
+	return median(slopes), true
+}
+
+// fitScale уточняет масштаб МНК по совпадениям, лежащим вдоль прямой дрейфа drift = slope*timeB + intercept
+func fitScale(matches []hashMatch, slope, intercept float64) (float64, bool) {
+	var n, sumB, sumA, sumBB, sumAB float64
+	for _, match := range matches {
+		tA, tB := float64(match.timeA), float64(match.timeB)
+		if math.Abs(tA-tB-(slope*tB+intercept)) > offsetDistortion {
+			continue
+		}
+		n++
+		sumB += tB
+		sumA += tA
+		sumBB += tB * tB
+		sumAB += tA * tB
+	}
+
+	if n < minAllowedCnt {
+		return 0, false
+	}
+	varB := sumBB - sumB*sumB/n
+	if varB <= 0 {
+		return 0, false
+	}
+	return (sumAB - sumA*sumB/n) / varB, true
+}
+
+// driftBin корзина гистограммы смещений timeA - timeB при поиске дрейфа
+func driftBin(match hashMatch) int32 {
+	d := int32(match.timeA) - int32(match.timeB)
+	return int32(math.Floor(float64(d) / scaleBinCols))
+}
+
+// median медиана значений (порядок vals меняется)
+func median(vals []float64) float64 {
+	sort.Float64s(vals)
+	l := len(vals)
+	if l%2 == 1 {
+		return vals[l/2]
+	}
+	return (vals[l/2-1] + vals[l/2]) / 2
+}
+
+// voteOffsets строит гистограмму смещений songA относительно songB, растянутого в scale раз.
+// Гистограмма берется из пула и должна быть возвращена через releaseVotes.
+func (m *Matcher) voteOffsets(matches []hashMatch, scale float64, offsetInCols int32) (votes offsetVotes) {
+	votes.offs = m.poolOffs.Get().(map[int32]int32)
+
+	for _, match := range matches {
+		stampA := float64(match.diffA)
+		stampB := float64(match.diffB) * scale
+
+		if math.Abs(stampA-stampB) >= timeDistortion {
+			continue
+		}
+
+		tDiff := int32(match.timeA) - int32(math.Round(float64(match.timeB)*scale))
+
+		if (tDiff < -offsetInCols) || (tDiff > offsetInCols) {
+			continue
+		}
+
+		if n, ok := votes.offs[tDiff]; !ok {
+			votes.offs[tDiff] = 1
+		} else {
+			if n++; int(n) > votes.cntInOffset {
+				votes.cntInOffset = int(n)
+				votes.offset = int(tDiff)
 			}
+			votes.offs[tDiff] = n
 		}
 	}
 
-	cntInOffset = 0
-	for i := (offset - offsetDistortion); i < (offset + offsetDistortion); i++ {
-		if o, ok := offs[int32(i)]; ok && (o >= minAllowedCnt) {
-			cntInOffset += int(o)
+	votes.cntInOffset = 0
+	for i := (votes.offset - offsetDistortion); i < (votes.offset + offsetDistortion); i++ {
+		if o, ok := votes.offs[int32(i)]; ok && (o >= minAllowedCnt) {
+			votes.cntInOffset += int(o)
 		}
 	}
 
-	cntOffs = 0
-	sumOffs = 0
-	for _, v := range offs {
-		if v >= minAllowedCnt {
-			sumOffs += int(v)
-			cntOffs++
+	return
+}
+
+// releaseVotes возвращает гистограмму смещений в пул
+func (m *Matcher) releaseVotes(votes offsetVotes) {
+	for k := range votes.offs {
+		delete(votes.offs, k)
+	}
+	m.poolOffs.Put(votes.offs)
+}
+
+func (m *Matcher) findOptimalOffset(songA, songB *PreparedHashes) (res MatchResult) {
+	buf := m.poolMatches.Get().([]hashMatch)
+	matches := m.collectMatches(songA.hashes, songB.hashes, buf)
+
+	offsetInCols := int32(math.Ceil(float64(maxTimeMsDiffForTracksCompare) / 1000 * m.colsInOneSec))
+
+	res = m.offsetFromMatches(matches, offsetInCols)
+	res.LenA, res.LenB = songA.Len(), songB.Len()
+
+	m.poolMatches.Put(matches[:0])
+
+	return
+}
+
+// offsetFromMatches выбирает оптимальные масштаб и смещение (не больше offsetInCols по модулю) по списку совпавших хешей
+func (m *Matcher) offsetFromMatches(matches []hashMatch, offsetInCols int32) (res MatchResult) {
+	var best offsetVotes
+	scale := float64(1)
+	for i, s := range m.estimateScales(matches) {
+		votes := m.voteOffsets(matches, s, offsetInCols)
+		if i == 0 {
+			best = votes
+		} else if votes.cntInOffset > best.cntInOffset {
+			m.releaseVotes(best)
+			best, scale = votes, s
+		} else {
+			m.releaseVotes(votes)
 		}
 	}
 
-	if swapped {
-		offset = -offset
-		songA, songB = songB, songA
+	res.Offset = best.offset
+	res.Scale = scale
+	res.CntInOffset = best.cntInOffset
+	res.MatchedHashes = len(matches)
+	res.RegionA, res.RegionB, res.Density = m.matchRegions(matches, best.offs, best.offset, scale)
+
+	for _, v := range best.offs {
+		if v >= minAllowedCnt {
+			res.SumOffs += int(v)
+			res.CntOffs++
+		}
 	}
 
+	m.releaseVotes(best)
+
 	return
 }
 
-func (m *Matcher) Match(songA Hashes, songB Hashes) (
-	score float64, offsetInSec float64, descr string,
-) {
-	offset, cntInOffset, sumOffs, cntOffs := m.findOptimalOffset(songA, songB)
+// matchRegions находит интервалы обоих треков, покрытые совпадениями в окрестности смещения offset
+// (учитываются только смещения, набравшие minAllowedCnt, как и в CntInOffset), и плотность совпадений по секундам songA
+func (m *Matcher) matchRegions(matches []hashMatch, offs map[int32]int32, offset int, scale float64) (regionA, regionB TimeSpan, density []int) {
+	minA, maxA := uint32(math.MaxUint32), uint32(0)
+	minB, maxB := uint32(math.MaxUint32), uint32(0)
 
-	if (cntOffs == 0) || (cntInOffset < minAllowedCnt) {
-		return 0, 0, `` // вообще фигня, а не то, что нужно
+	var timesA []uint32
+	for _, match := range matches {
+		if math.Abs(float64(match.diffA)-float64(match.diffB)*scale) >= timeDistortion {
+			continue
+		}
+
+		tDiff := int(match.timeA) - int(math.Round(float64(match.timeB)*scale))
+		if (tDiff < offset-offsetDistortion) || (tDiff >= offset+offsetDistortion) || (offs[int32(tDiff)] < minAllowedCnt) {
+			continue
+		}
+
+		timesA = append(timesA, match.timeA)
+		if match.timeA < minA {
+			minA = match.timeA
+		}
+		if match.timeA > maxA {
+			maxA = match.timeA
+		}
+		if match.timeB < minB {
+			minB = match.timeB
+		}
+		if match.timeB > maxB {
+			maxB = match.timeB
+		}
 	}
 
-	songALen := len(songA)
-	songBLen := len(songB)
+	if len(timesA) == 0 {
+		return
+	}
 
-	offsetInSec = float64(offset) / (float64(SampleRate) / float64(FFTWinSize/2))
-	var cntInOffsetPerc float64
-	if l := float64(minInt(songALen, songBLen)); l > 0 {
-		cntInOffsetPerc = 100.0 * float64(cntInOffset) / l
+	regionA = TimeSpan{Start: float64(minA) / m.colsInOneSec, End: float64(maxA) / m.colsInOneSec}
+	regionB = TimeSpan{Start: float64(minB) / m.colsInOneSec, End: float64(maxB) / m.colsInOneSec}
+
+	density = make([]int, int(regionA.Duration())+1)
+	for _, t := range timesA {
+		density[int(float64(t-minA)/m.colsInOneSec)]++
 	}
 
-	score = float64(cntInOffset)
-	scoreK := float64(1.0)
+	return
+}
 
-	maxOffsetInSec := maxTimeMsDiffForTracksCompare / 1000
+// Duration длина интервала в секундах
+func (s TimeSpan) Duration() float64 {
+	return s.End - s.Start
+}
+
+// Match сравнивает два трека. Списки хешей не изменяются; при многократном сравнении одного трека
+// выгоднее один раз подготовить его через Prepare и использовать MatchPrepared.
+func (m *Matcher) Match(songA Hashes, songB Hashes) MatchResult {
+	bufA, bufB := m.poolHashes.Get().(Hashes), m.poolHashes.Get().(Hashes)
+	preparedA, preparedB := m.prepare(songA, bufA), m.prepare(songB, bufB)
+
+	res := m.MatchPrepared(preparedA, preparedB)
+
+	m.poolHashes.Put(preparedA.hashes[:0])
+	m.poolHashes.Put(preparedB.hashes[:0])
+
+	return res
+}
 
-	gaus := m.gaus.Make(maxOffsetInSec, float64(maxOffsetInSec)/3)
+// scoreResult по найденному смещению вычисляет итоговые score, покрытие и похожесть двух треков.
+// Смещение больше maxTimeMsDiffForTracksCompare не допускается, меньшее - штрафуется (offsetPenalty).
+func (m *Matcher) scoreResult(res *MatchResult) {
+	m.score(res, true)
+}
+
+// scoreClipResult аналог scoreResult для поиска фрагмента в каталоге: фрагмент может быть взят из любого
+// места трека, поэтому смещение не ограничивается и не штрафуется (ScoreK = 1)
+func (m *Matcher) scoreClipResult(res *MatchResult) {
+	m.score(res, false)
+}
 
-	offsetToIdx := int(math.Floor(offsetInSec))
+func (m *Matcher) score(res *MatchResult, penalizeOffset bool) {
+	if (res.CntOffs == 0) || (res.CntInOffset < minAllowedCnt) {
+		res.reset() // вообще фигня, а не то, что нужно
+		return
+	}
 
-	if (offsetToIdx <= -maxOffsetInSec) || (offsetToIdx >= maxOffsetInSec) {
-		return 0, 0, `` // сдвиг по времени слишком большой
-	} else {
-		scoreK = float64(gaus[maxOffsetInSec+offsetToIdx])
+	res.OffsetInSec = float64(res.Offset) / m.colsInOneSec
+	if l := float64(minInt(res.LenA, res.LenB)); l > 0 {
+		res.CntInOffsetPerc = 100.0 * float64(res.CntInOffset) / l
 	}
 
-	score *= scoreK
+	res.ScoreK = 1
+	if penalizeOffset {
+		maxOffsetInSec := maxTimeMsDiffForTracksCompare / 1000
+
+		offsetToIdx := int(math.Floor(res.OffsetInSec))
 
-	descr = fmt.Sprintf("offset: %5d cntInOffset: %5d (%5.1f%%) sumOffs: %5d cntOffs: %5d lenA: %6d lenB: %6d scoreK: %5.3f",
-		offset, cntInOffset, cntInOffsetPerc, sumOffs, cntOffs, len(songA), len(songB), scoreK,
+		if (offsetToIdx <= -maxOffsetInSec) || (offsetToIdx >= maxOffsetInSec) {
+			res.reset() // сдвиг по времени слишком большой
+			return
+		}
+
+		res.ScoreK = m.offsetPenalty[maxOffsetInSec+offsetToIdx]
+	}
+	res.Score = float64(res.CntInOffset) * res.ScoreK
+
+	if l := minInt(res.LenA, res.LenB); l > 0 {
+		res.Similarity = eq2similarity(res.Score / float64(l))
+	}
+}
+
+// reset обнуляет результат сравнения, оставляя только информацию о входных данных
+func (res *MatchResult) reset() {
+	*res = MatchResult{
+		Scale:         1,
+		MatchedHashes: res.MatchedHashes,
+		LenA:          res.LenA,
+		LenB:          res.LenB,
+	}
+}
+
+// String формирует текстовое описание результата (для логов и отладки)
+func (res MatchResult) String() string {
+	return fmt.Sprintf("offset: %5d scale: %5.3f cntInOffset: %5d (%5.1f%%) sumOffs: %5d cntOffs: %5d matched: %6d lenA: %6d lenB: %6d scoreK: %5.3f similarity: %5.3f regionA: %s regionB: %s",
+		res.Offset, res.Scale, res.CntInOffset, res.CntInOffsetPerc, res.SumOffs, res.CntOffs, res.MatchedHashes, res.LenA, res.LenB, res.ScoreK, res.Similarity,
+		res.RegionA, res.RegionB,
 	)
+}
 
-	return
+func (s TimeSpan) String() string {
+	return fmt.Sprintf(`%.1f-%.1f`, s.Start, s.End)
+}
+
+// eq2similarity переводит долю совпавших хешей (0..1) в нормированную похожесть 0..1.
+// Самый примитивный вариант подсчета итоговой похожести.
+func eq2similarity(eq float64) float64 {
+	if eq <= 0 {
+		return 0
+	}
+
+	sim := math.Pow(eq, 1.0/3.0) - 0.3
+	return math.Min(1, 1.4*math.Max(0, sim))
 }
@@ -0,0 +1,115 @@
+package fennec
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testMusic синтезирует "музыку" из последовательности нот (пар тонов) со случайными частотами.
+// tempo растягивает длительность нот без изменения их высоты, т.е. дает трек, растянутый во времени.
+func testMusic(sec float64, tempo float64, seed int64) []Float {
+	const sampleRate = 11025
+
+	r := rand.New(rand.NewSource(seed))
+	n := int(sec * tempo * sampleRate)
+	noteLen := int(math.Round(sampleRate / 4 * tempo))
+
+	pcm := make([]Float, n)
+	for start := 0; start < n; start += noteLen {
+		f1, f2 := 200+r.Float64()*3000, 200+r.Float64()*3000
+		for i := start; (i < start+noteLen) && (i < n); i++ {
+			x := float64(i-start) / sampleRate
+			env := math.Exp(-float64(i-start) / (2000 * tempo))
+			pcm[i] = Float(0.4 * env * (math.Sin(2*math.Pi*f1*x) + math.Sin(2*math.Pi*f2*x)))
+		}
+	}
+	return pcm
+}
+
+func testHashes(t *testing.T, pcm []Float) Hashes {
+	t.Helper()
+	hashes, err := DefaultFingerprinter().Hashes(pcm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hashes
+}
+
+func TestMatchTimeStretch(t *testing.T) {
+	orig := testHashes(t, testMusic(60, 1, 1))
+	m := NewMatcher()
+
+	if res := m.Match(orig, orig); res.Scale != 1 {
+		t.Errorf("same track: scale %.4f, expected 1", res.Scale)
+	}
+
+	for _, tempo := range []float64{0.93, 0.97, 1.02, 1.06, 1.15} {
+		stretched := testHashes(t, testMusic(60, tempo, 1))
+
+		res := m.Match(stretched, orig)
+		t.Logf("tempo %.2f: %s", tempo, res)
+		if math.Abs(res.Scale-tempo) > 0.005 {
+			t.Errorf("tempo %.2f: scale %.4f", tempo, res.Scale)
+		}
+		if res.Similarity < 0.2 {
+			t.Errorf("tempo %.2f: similarity %.3f", tempo, res.Similarity)
+		}
+	}
+
+	if res := m.Match(testHashes(t, testMusic(60, 1, 2)), orig); res.Similarity > 0 {
+		t.Errorf("unrelated track: %s", res)
+	}
+}
+
+// TestMatchRegions проверяет интервалы совпадения и плотность для фрагмента трека с тишиной в середине
+func TestMatchRegions(t *testing.T) {
+	const (
+		clipFrom, clipTo = 20, 40
+		// тишина внутри фрагмента, в секундах от начала фрагмента
+		gapFrom, gapTo = 8, 12
+	)
+
+	track := testMusic(60, 1, 1)
+	clip := append([]Float(nil), track[clipFrom*SampleRate:clipTo*SampleRate]...)
+	for i := gapFrom * SampleRate; i < gapTo*SampleRate; i++ {
+		clip[i] = 0
+	}
+
+	res := NewMatcher().Match(testHashes(t, track), testHashes(t, clip))
+	t.Logf("%s density %v", res, res.Density)
+
+	if math.Abs(res.OffsetInSec-clipFrom) > 0.1 {
+		t.Errorf("offset %.2f, expected %d", res.OffsetInSec, clipFrom)
+	}
+
+	near := func(span TimeSpan, start, end float64) bool {
+		return (math.Abs(span.Start-start) <= 1) && (math.Abs(span.End-end) <= 1)
+	}
+	if !near(res.RegionA, clipFrom, clipTo) || !near(res.RegionB, 0, clipTo-clipFrom) {
+		t.Fatalf("regionA %s, regionB %s", res.RegionA, res.RegionB)
+	}
+
+	if l := int(res.RegionA.Duration()) + 1; len(res.Density) != l {
+		t.Fatalf("density for %d seconds, expected %d", len(res.Density), l)
+	}
+
+	sum := 0
+	for _, d := range res.Density {
+		sum += d
+	}
+	if sum != res.CntInOffset {
+		t.Errorf("density sums to %d, expected %d matched in offset", sum, res.CntInOffset)
+	}
+
+	// секунды целиком внутри тишины пустые, целиком вне ее (кроме краев) - нет
+	for i, d := range res.Density {
+		secStart := res.RegionA.Start + float64(i) - clipFrom
+		secEnd := secStart + 1
+		inGap := (secStart >= gapFrom) && (secEnd <= gapTo)
+		inMusic := ((secEnd <= gapFrom) || (secStart >= gapTo)) && (i > 0) && (i < len(res.Density)-1)
+		if (inGap && (d != 0)) || (inMusic && (d == 0)) {
+			t.Errorf("second %d (%.1f-%.1f of clip): %d hashes", i, secStart, secEnd, d)
+		}
+	}
+}
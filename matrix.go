@@ -0,0 +1,84 @@
+package fennec
+
+import (
+	"context"
+)
+
+type (
+	// MatrixPair результат сравнения треков A < B матрицы (как MatchPrepared(A, B))
+	MatrixPair struct {
+		A, B int
+		MatchResult
+	}
+
+	// SimilarityMatrix попарная похожесть набора треков
+	SimilarityMatrix struct {
+		Paths []string
+		// Errors[i] ошибка чтения или построения отпечатка i-го трека. Такой трек ни с чем не сравнивается.
+		Errors []error
+		// Similarity[i][j] похожесть i-го и j-го треков (симметрична, на диагонали 1 для треков без ошибок)
+		Similarity [][]float64
+		// Pairs все сравненные пары в порядке (A, B)
+		Pairs []MatrixPair
+	}
+)
+
+// SimilarityMatrixFromFiles строит отпечаток каждого файла (один раз) и сравнивает все пары треков.
+// И отпечатки, и сравнения считаются на GOMAXPROCS горутинах.
+// Ошибки отдельных файлов попадают в SimilarityMatrix.Errors; сама функция возвращает ошибку только при
+// несовпадении версий fp и m или при отмене ctx.
+func SimilarityMatrixFromFiles(ctx context.Context, fp *Fingerprinter, m *Matcher, paths []string) (*SimilarityMatrix, error) {
+	if err := checkVersion(m.version, fp.version); err != nil {
+		return nil, err
+	}
+
+	n := len(paths)
+	sm := &SimilarityMatrix{
+		Paths:      paths,
+		Errors:     make([]error, n),
+		Similarity: make([][]float64, n),
+	}
+	for i := range sm.Similarity {
+		sm.Similarity[i] = make([]float64, n)
+	}
+
+	prepared := make([]*PreparedHashes, n)
+	err := parallel(ctx, n, func(i int) {
+		hashes, err := fp.HashesFromFile(paths[i])
+		if err != nil {
+			sm.Errors[i] = err
+			return
+		}
+		prepared[i] = m.Prepare(hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		if sm.Errors[i] != nil {
+			continue
+		}
+		sm.Similarity[i][i] = 1
+		for j := i + 1; j < n; j++ {
+			if sm.Errors[j] == nil {
+				sm.Pairs = append(sm.Pairs, MatrixPair{A: i, B: j})
+			}
+		}
+	}
+
+	err = parallel(ctx, len(sm.Pairs), func(idx int) {
+		pair := &sm.Pairs[idx]
+		pair.MatchResult = m.MatchPrepared(prepared[pair.A], prepared[pair.B])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range sm.Pairs {
+		sm.Similarity[pair.A][pair.B] = pair.Similarity
+		sm.Similarity[pair.B][pair.A] = pair.Similarity
+	}
+
+	return sm, nil
+}
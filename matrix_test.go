@@ -0,0 +1,87 @@
+package fennec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testWriteWav сохраняет моно PCM с частотой SampleRate в 16-битный WAV
+func testWriteWav(t *testing.T, path string, pcm []Float) {
+	t.Helper()
+
+	samples := make([]int16, len(pcm))
+	for i, v := range pcm {
+		samples[i] = int16(v * (int16ToFloat - 1))
+	}
+
+	format := RawPCMFormat{SampleRate: SampleRate, Channels: 1, BitsPerSample: 16}
+	if err := os.WriteFile(path, testWavSized(format, false, testWavData(format, samples)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimilarityMatrixFromFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	track := testMusic(60, 1, 1)
+	paths := []string{
+		filepath.Join(dir, `track.wav`),
+		filepath.Join(dir, `clip.wav`),
+		filepath.Join(dir, `other.wav`),
+		filepath.Join(dir, `broken.wav`),
+	}
+	testWriteWav(t, paths[0], track)
+	testWriteWav(t, paths[1], track[20*SampleRate:45*SampleRate])
+	testWriteWav(t, paths[2], testMusic(60, 1, 2))
+	if err := os.WriteFile(paths[3], []byte(`not a wav`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := SimilarityMatrixFromFiles(context.Background(), DefaultFingerprinter(), NewMatcher(), paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range sm.Similarity {
+		t.Logf("%s %.3f %v", filepath.Base(paths[i]), row, sm.Errors[i])
+	}
+
+	for i := 0; i < 3; i++ {
+		if sm.Errors[i] != nil {
+			t.Fatalf("%s: %s", paths[i], sm.Errors[i])
+		}
+	}
+	if sm.Errors[3] == nil {
+		t.Error("no error for broken file")
+	}
+	if len(sm.Pairs) != 3 {
+		t.Errorf("%d pairs, expected 3", len(sm.Pairs))
+	}
+
+	for i := range sm.Similarity {
+		for j := range sm.Similarity[i] {
+			if sm.Similarity[i][j] != sm.Similarity[j][i] {
+				t.Errorf("[%d][%d] = %.3f, [%d][%d] = %.3f", i, j, sm.Similarity[i][j], j, i, sm.Similarity[j][i])
+			}
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if sm.Similarity[i][i] < 0.999 {
+			t.Errorf("%s: self similarity %.3f", paths[i], sm.Similarity[i][i])
+		}
+	}
+	for j := range sm.Similarity[3] {
+		if sm.Similarity[3][j] != 0 {
+			t.Errorf("broken file: similarity %.3f with %d", sm.Similarity[3][j], j)
+		}
+	}
+
+	if sim := sm.Similarity[0][1]; sim < 0.3 {
+		t.Errorf("track and its clip: similarity %.3f", sim)
+	}
+	if sim := sm.Similarity[0][2]; sim > 0.1 {
+		t.Errorf("unrelated tracks: similarity %.3f", sim)
+	}
+}
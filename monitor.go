@@ -0,0 +1,321 @@
+package fennec
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// допустимый дрейф положения трека между окнами, при котором это все еще то же самое воспроизведение
+	monitorOffsetToleranceSec = 1.0
+)
+
+type (
+	// Catalog каталог треков, по которому ищутся фрагменты (Index, DiskIndex)
+	Catalog interface {
+		Query(hashes Hashes, topK int) []QueryResult
+		Version() FingerprintVersion
+	}
+
+	// MonitorConfig параметры мониторинга потока
+	MonitorConfig struct {
+		// длина окна потока, которое ищется в каталоге
+		Window time.Duration
+		// шаг сдвига окна
+		Step time.Duration
+		// сколько лучших треков каталога рассматривается в каждом окне
+		TopK int
+		// минимальная похожесть окна с треком (MatchResult.Similarity), чтобы окно считалось совпавшим
+		MinSimilarity float64
+		// гистерезис: сколько окон подряд должно совпасть, чтобы объявить начало трека,
+		// и сколько подряд не совпасть, чтобы объявить его конец
+		StartHits  int
+		StopMisses int
+	}
+
+	MonitorEventType int
+
+	// MonitorEvent начало или конец воспроизведения трека каталога в потоке
+	MonitorEvent struct {
+		Type    MonitorEventType
+		TrackID TrackID
+
+		// интервал потока, в котором трек совпадал. Для MonitorStart StreamEnd - момент обнаружения.
+		StreamStart time.Duration
+		StreamEnd   time.Duration
+		// позиция в треке, соответствующая StreamStart
+		TrackOffset time.Duration
+		// средняя похожесть (0..1) по совпавшим окнам
+		Confidence float64
+	}
+
+	// Monitor непрерывно ищет скользящее окно бесконечного потока в каталоге и сообщает о начале
+	// и конце воспроизведения известных треков. Не безопасен для конкурентного использования.
+	Monitor struct {
+		cfg     MonitorConfig
+		catalog Catalog
+		stream  *StreamFingerprinter
+
+		colsInOneSec float64
+		windowCols   int
+		stepCols     int
+		toleranceCol int
+
+		// хеши потока, еще нужные следующим окнам (по возрастанию Time)
+		hashes Hashes
+		// конец следующего окна (в колонках потока)
+		nextEnd int
+		// конец последнего проверенного окна
+		lastEnd int
+
+		tracks map[TrackID]*monitorTrack
+	}
+
+	// monitorTrack состояние трека, совпадавшего в последних окнах
+	monitorTrack struct {
+		playing      bool
+		hits, misses int
+
+		// колонка потока, на которую приходится начало трека (по последнему совпавшему окну)
+		zero int
+		// интервал потока, покрытый совпадениями в совпавших окнах
+		start, end int
+
+		simSum float64
+		simCnt int
+	}
+)
+
+const (
+	MonitorStart MonitorEventType = iota
+	MonitorStop
+)
+
+var (
+	DefaultMonitorConfig = MonitorConfig{
+		Window:        10 * time.Second,
+		Step:          2 * time.Second,
+		TopK:          5,
+		MinSimilarity: 0.3,
+		StartHits:     2,
+		StopMisses:    3,
+	}
+)
+
+func (t MonitorEventType) String() string {
+	switch t {
+	case MonitorStart:
+		return `start`
+	case MonitorStop:
+		return `stop`
+	default:
+		return `unknown`
+	}
+}
+
+// NewMonitor создает монитор потока. Хеши строятся fp, который должен быть той же версии, что и каталог.
+func NewMonitor(catalog Catalog, fp *Fingerprinter, cfg MonitorConfig) (*Monitor, error) {
+	if err := checkVersion(catalog.Version(), fp.Version()); err != nil {
+		return nil, err
+	}
+
+	m, err := newMonitor(catalog, fp.cfg.HashColsInOneSec(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	m.stream = fp.NewStream()
+
+	return m, nil
+}
+
+func newMonitor(catalog Catalog, colsInOneSec float64, cfg MonitorConfig) (*Monitor, error) {
+	if (cfg.Window <= 0) || (cfg.Step <= 0) || (cfg.StartHits < 1) || (cfg.StopMisses < 1) {
+		return nil, ErrWrongParams
+	}
+
+	m := &Monitor{
+		cfg:          cfg,
+		catalog:      catalog,
+		colsInOneSec: colsInOneSec,
+		windowCols:   maxInt(1, int(math.Round(cfg.Window.Seconds()*colsInOneSec))),
+		stepCols:     maxInt(1, int(math.Round(cfg.Step.Seconds()*colsInOneSec))),
+		toleranceCol: int(math.Ceil(monitorOffsetToleranceSec * colsInOneSec)),
+		tracks:       make(map[TrackID]*monitorTrack),
+	}
+	m.nextEnd = m.windowCols
+
+	return m, nil
+}
+
+// Push добавляет очередную порцию моно PCM потока и возвращает произошедшие события
+func (m *Monitor) Push(pcm []Float) ([]MonitorEvent, error) {
+	if m.stream == nil {
+		return nil, ErrWrongParams
+	}
+
+	hashes, err := m.stream.Push(pcm)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.PushHashes(hashes, m.stream.paired), nil
+}
+
+// PushHashes добавляет уже посчитанные хеши потока (Time - колонки от начала потока, по возрастанию).
+// completeTo - все хеши с Time < completeTo уже переданы, так что окна до этого момента можно проверять.
+func (m *Monitor) PushHashes(hashes Hashes, completeTo int) (events []MonitorEvent) {
+	m.hashes = append(m.hashes, hashes...)
+
+	for m.nextEnd <= completeTo {
+		events = m.checkWindow(m.nextEnd, events)
+		m.nextEnd += m.stepCols
+	}
+
+	return events
+}
+
+// Flush завершает поток: проверяет последнее неполное окно и завершает все играющие треки
+func (m *Monitor) Flush() ([]MonitorEvent, error) {
+	if m.stream == nil {
+		return nil, ErrWrongParams
+	}
+
+	hashes, err := m.stream.Flush()
+	if (err != nil) && (err != ErrTooShort) && (err != ErrSilentInput) {
+		return nil, err
+	}
+
+	events := m.PushHashes(hashes, m.stream.paired)
+	return m.flush(events), nil
+}
+
+// FlushHashes аналог Flush для хешей, переданных через PushHashes
+func (m *Monitor) FlushHashes() []MonitorEvent {
+	return m.flush(nil)
+}
+
+func (m *Monitor) flush(events []MonitorEvent) []MonitorEvent {
+	if l := len(m.hashes); l > 0 {
+		if end := int(m.hashes[l-1].Time) + 1; end > m.lastEnd {
+			events = m.checkWindow(end, events)
+		}
+	}
+
+	ids := make([]TrackID, 0, len(m.tracks))
+	for id := range m.tracks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if t := m.tracks[id]; t.playing {
+			events = append(events, m.event(MonitorStop, id, t))
+		}
+		delete(m.tracks, id)
+	}
+
+	return events
+}
+
+// checkWindow ищет в каталоге окно потока [end-windowCols, end)
+func (m *Monitor) checkWindow(end int, events []MonitorEvent) []MonitorEvent {
+	start := maxInt(0, end-m.windowCols)
+	m.lastEnd = end
+
+	// хеши раньше начала окна больше не понадобятся и следующим окнам
+	drop := sort.Search(len(m.hashes), func(i int) bool { return int(m.hashes[i].Time) >= start })
+	m.hashes = append(m.hashes[0:0], m.hashes[drop:]...)
+
+	var window Hashes
+	for _, h := range m.hashes {
+		if int(h.Time) >= end {
+			break
+		}
+		window = append(window, Hash{Time: h.Time - uint32(start), Hash: h.Hash})
+	}
+
+	matched := make(map[TrackID]bool)
+	if len(window) > 0 {
+		for _, res := range m.catalog.Query(window, m.cfg.TopK) {
+			if res.Similarity < m.cfg.MinSimilarity {
+				continue
+			}
+			// Offset - позиция начала окна в треке, RegionB - часть окна, реально покрытая совпадениями
+			regStart := start + int(res.RegionB.Start*m.colsInOneSec)
+			regEnd := start + int(math.Ceil(res.RegionB.End*m.colsInOneSec)) + 1
+			if m.hit(res.TrackID, regStart, minInt(regEnd, end), start-res.Offset, res.Similarity, &events) {
+				matched[res.TrackID] = true
+			}
+		}
+	}
+
+	ids := make([]TrackID, 0, len(m.tracks))
+	for id := range m.tracks {
+		if !matched[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		t := m.tracks[id]
+		if !t.playing {
+			delete(m.tracks, id)
+			continue
+		}
+
+		if t.misses++; t.misses >= m.cfg.StopMisses {
+			events = append(events, m.event(MonitorStop, id, t))
+			delete(m.tracks, id)
+		}
+	}
+
+	return events
+}
+
+// hit учитывает совпадение с треком участка окна [start, end). Возвращает false, если совпадение противоречит
+// уже играющему воспроизведению трека (другое место трека) и считается промахом.
+func (m *Monitor) hit(id TrackID, start, end, zero int, sim float64, events *[]MonitorEvent) bool {
+	t, ok := m.tracks[id]
+	if ok && (absInt(zero-t.zero) > m.toleranceCol) {
+		if t.playing {
+			return false
+		}
+		ok = false
+	}
+
+	if !ok {
+		t = &monitorTrack{start: maxInt(start, zero)}
+		m.tracks[id] = t
+	}
+
+	t.zero = zero
+	t.end = maxInt(t.end, end)
+	t.hits++
+	t.misses = 0
+	t.simSum += sim
+	t.simCnt++
+
+	if !t.playing && (t.hits >= m.cfg.StartHits) {
+		t.playing = true
+		*events = append(*events, m.event(MonitorStart, id, t))
+	}
+
+	return true
+}
+
+func (m *Monitor) event(typ MonitorEventType, id TrackID, t *monitorTrack) MonitorEvent {
+	return MonitorEvent{
+		Type:        typ,
+		TrackID:     id,
+		StreamStart: m.colsToDuration(t.start),
+		StreamEnd:   m.colsToDuration(t.end),
+		TrackOffset: m.colsToDuration(t.start - t.zero),
+		Confidence:  t.simSum / float64(t.simCnt),
+	}
+}
+
+func (m *Monitor) colsToDuration(cols int) time.Duration {
+	return time.Duration(float64(cols) / m.colsInOneSec * float64(time.Second))
+}
@@ -0,0 +1,115 @@
+package fennec
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testMonitorEvents прогоняет поток через монитор порциями по секунде и возвращает все события
+func testMonitorEvents(t *testing.T, catalog Catalog, stream []Float, cfg MonitorConfig) []MonitorEvent {
+	t.Helper()
+
+	m, err := NewMonitor(catalog, DefaultFingerprinter(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []MonitorEvent
+	for pos := 0; pos < len(stream); pos += SampleRate {
+		evs, err := m.Push(stream[pos:minInt(len(stream), pos+SampleRate)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, evs...)
+	}
+	evs, err := m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(events, evs...)
+}
+
+// TestMonitorHysteresis проверяет, что короткий провал внутри трека (меньше StopMisses окон) не завершает его,
+// и что на каждый трек приходится ровно одна пара событий start/stop с правильными временами.
+func TestMonitorHysteresis(t *testing.T) {
+	trackA, trackB := testMusic(60, 1, 1), testMusic(60, 1, 2)
+
+	idx := NewIndex()
+	idx.Add(1, testHashes(t, trackA))
+	idx.Add(2, testHashes(t, trackB))
+
+	silence := func(sec float64) []Float { return make([]Float, int(sec*SampleRate)) }
+	part := func(pcm []Float, from, to float64) []Float { return pcm[int(from*SampleRate):int(to*SampleRate)] }
+
+	// 20s тишины, A[0:30), провал 8s вместо A[30:38), A[38:60), B[0:40), 10s тишины
+	var stream []Float
+	stream = append(stream, silence(20)...)
+	stream = append(stream, part(trackA, 0, 30)...)
+	stream = append(stream, silence(8)...)
+	stream = append(stream, part(trackA, 38, 60)...)
+	stream = append(stream, part(trackB, 0, 40)...)
+	stream = append(stream, silence(10)...)
+
+	// провал действительно дает промахи: без гистерезиса трек A в нем завершается
+	noHysteresis := DefaultMonitorConfig
+	noHysteresis.StopMisses = 1
+	stops := 0
+	for _, ev := range testMonitorEvents(t, idx, stream, noHysteresis) {
+		if (ev.Type == MonitorStop) && (ev.TrackID == 1) {
+			stops++
+		}
+	}
+	if stops < 2 {
+		t.Fatalf("gap does not produce missed windows (%d stops of track A)", stops)
+	}
+
+	events := testMonitorEvents(t, idx, stream, DefaultMonitorConfig)
+
+	// конец трека объявляется после StopMisses окон, а начало следующего - после StartHits,
+	// поэтому start следующего трека может прийти раньше stop предыдущего; порядок проверяется внутри трека
+	expected := map[TrackID][]struct {
+		typ         MonitorEventType
+		streamStart float64
+		streamEnd   float64
+	}{
+		1: {{MonitorStart, 20, -1}, {MonitorStop, 20, 80}},
+		2: {{MonitorStart, 80, -1}, {MonitorStop, 80, 120}},
+	}
+
+	byTrack := make(map[TrackID][]MonitorEvent)
+	for _, ev := range events {
+		t.Logf("%s %d: stream %s-%s track %s confidence %.3f", ev.Type, ev.TrackID, ev.StreamStart, ev.StreamEnd, ev.TrackOffset, ev.Confidence)
+		byTrack[ev.TrackID] = append(byTrack[ev.TrackID], ev)
+	}
+	if len(byTrack) != len(expected) {
+		t.Errorf("events for %d tracks, expected %d", len(byTrack), len(expected))
+	}
+
+	near := func(d time.Duration, sec float64) bool { return math.Abs(d.Seconds()-sec) <= 2 }
+
+	for id, exp := range expected {
+		evs := byTrack[id]
+		if len(evs) != len(exp) {
+			t.Errorf("track %d: %d events, expected %d", id, len(evs), len(exp))
+			continue
+		}
+
+		for i, ev := range evs {
+			if ev.Type != exp[i].typ {
+				t.Errorf("track %d event %d: %s, expected %s", id, i, ev.Type, exp[i].typ)
+			}
+			// трек играет с начала, так что TrackOffset около нуля
+			if !near(ev.StreamStart, exp[i].streamStart) || !near(ev.TrackOffset, 0) {
+				t.Errorf("track %d event %d: stream start %s, track offset %s", id, i, ev.StreamStart, ev.TrackOffset)
+			}
+			if (exp[i].streamEnd >= 0) && !near(ev.StreamEnd, exp[i].streamEnd) {
+				t.Errorf("track %d event %d: stream end %s", id, i, ev.StreamEnd)
+			}
+			if ev.Confidence < DefaultMonitorConfig.MinSimilarity {
+				t.Errorf("track %d event %d: confidence %.3f", id, i, ev.Confidence)
+			}
+		}
+	}
+}
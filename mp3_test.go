@@ -0,0 +1,244 @@
+package fennec
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type (
+	// testBitWriter пишет биты в порядке MSB first, как в потоке MPEG
+	testBitWriter struct {
+		buf  []byte
+		bits int
+	}
+
+	// testMp3Layout параметры фрейма Layer III (моно, 128 kbps для MPEG-1 и 64 kbps для MPEG-2)
+	testMp3Layout struct {
+		header   [4]byte
+		frameLen int
+		granules int
+		lsf      bool
+	}
+)
+
+var (
+	testMp3Layouts = map[int]testMp3Layout{
+		44100: {header: [4]byte{0xFF, 0xFB, 0x90, 0xC0}, frameLen: 417, granules: 2},
+		22050: {header: [4]byte{0xFF, 0xF3, 0x80, 0xC0}, frameLen: 208, granules: 1, lsf: true},
+	}
+)
+
+func (w *testBitWriter) write(v uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if (v>>uint(i))&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> uint(w.bits%8)
+		}
+		w.bits++
+	}
+}
+
+// testMp3Granule кодирует линии спектра со значениями 0 и ±1 таблицей Хаффмана 1 (только big_values)
+func testMp3Granule(lines []int) (data testBitWriter, bigValues int) {
+	last := -1
+	for i, v := range lines {
+		if v != 0 {
+			last = i
+		}
+	}
+	bigValues = last/2 + 1
+
+	// коды таблицы 1 для пар (x, y)
+	codes := [2][2]struct {
+		code uint32
+		len  int
+	}{{{1, 1}, {1, 3}}, {{1, 2}, {0, 3}}}
+
+	for i := 0; i < 2*bigValues; i += 2 {
+		x, y := lines[i], lines[i+1]
+		c := codes[x*x][y*y]
+		data.write(c.code, c.len)
+		for _, v := range []int{x, y} {
+			if v < 0 {
+				data.write(1, 1)
+			} else if v > 0 {
+				data.write(0, 1)
+			}
+		}
+	}
+
+	return data, bigValues
+}
+
+// testMp3 кодирует в моно MPEG Layer III (без резервуара бит) ноты по 1/4 секунды из двух затухающих тонов.
+// Каждый тон - одна ненулевая линия спектра гранулы, затухание - уменьшение global_gain.
+// Вторым значением возвращается тот же звук, синтезированный напрямую с частотой 11025.
+func testMp3(sec float64, sampleRate int, seed int64) ([]byte, []Float) {
+	const (
+		grSamples = 576
+		refRate   = 11025
+	)
+
+	layout := testMp3Layouts[sampleRate]
+	r := rand.New(rand.NewSource(seed))
+
+	grCnt := int(sec * float64(sampleRate) / grSamples)
+	grCnt -= grCnt % layout.granules
+	noteGranules := int(math.Round(float64(sampleRate) / 4 / grSamples))
+	lineHz := float64(sampleRate) / 2 / grSamples
+
+	// частоты линий не выше 3 КГц, чтобы оставаться ниже Найквиста refRate
+	maxLine := int(3000 / lineHz)
+
+	ref := make([]Float, int(float64(grCnt*grSamples)*refRate/float64(sampleRate)))
+
+	var (
+		out        []byte
+		frame      testBitWriter
+		k1, k2, gr int
+		grData     []testBitWriter
+	)
+	for ; gr < grCnt; gr++ {
+		step := gr % noteGranules
+		if step == 0 {
+			k1, k2 = 10+r.Intn(maxLine-10), 10+r.Intn(maxLine-10)
+			if k1 == k2 {
+				k2++
+			}
+		}
+
+		lines := make([]int, grSamples)
+		lines[k1], lines[k2] = 1, 1
+		data, bigValues := testMp3Granule(lines)
+		gain := 200 - step
+
+		// тот же звук напрямую: линия k звучит на частоте (k+1)*lineHz, затухание ступенчатое 2^(-step/4), как у global_gain
+		amp := 0.4 * math.Pow(2, -float64(step)/4)
+		f1, f2 := float64(k1+1)*lineHz, float64(k2+1)*lineHz
+		from, to := gr*grSamples*refRate/sampleRate, (gr+1)*grSamples*refRate/sampleRate
+		for i := from; (i < to) && (i < len(ref)); i++ {
+			x := float64(i) / refRate
+			ref[i] = Float(amp * (math.Sin(2*math.Pi*f1*x) + math.Sin(2*math.Pi*f2*x)))
+		}
+
+		if gr%layout.granules == 0 {
+			frame = testBitWriter{}
+			for _, b := range layout.header {
+				frame.write(uint32(b), 8)
+			}
+			if layout.lsf {
+				frame.write(0, 8+1) // main_data_begin, private_bits
+			} else {
+				frame.write(0, 9+5+4) // main_data_begin, private_bits, scfsi
+			}
+			grData = grData[:0]
+		}
+
+		frame.write(uint32(data.bits), 12) // part2_3_length
+		frame.write(uint32(bigValues), 9)
+		frame.write(uint32(gain), 8)
+		if layout.lsf {
+			frame.write(0, 9) // scalefac_compress
+		} else {
+			frame.write(0, 4)
+		}
+		frame.write(0, 1) // window_switching_flag
+		for i := 0; i < 3; i++ {
+			frame.write(1, 5) // table_select
+		}
+		frame.write(7, 4) // region0_count
+		frame.write(7, 3) // region1_count
+		if !layout.lsf {
+			frame.write(0, 1) // preflag
+		}
+		frame.write(0, 1+1) // scalefac_scale, count1table_select
+		grData = append(grData, data)
+
+		if len(grData) == layout.granules {
+			for _, d := range grData {
+				for i := 0; i < d.bits; i++ {
+					frame.write(uint32(d.buf[i/8]>>uint(7-i%8))&1, 1)
+				}
+			}
+			for frame.bits < layout.frameLen*8 {
+				frame.write(0, 1)
+			}
+			out = append(out, frame.buf...)
+		}
+	}
+
+	return out, ref
+}
+
+// TestMp3Decode проверяет, что отпечаток декодированного MP3 совпадает с отпечатком того же звука без сжатия.
+// Тест одинаков для обоих декодеров (libmad и go-mp3 с тегом purego), так что они проверяются на одних данных.
+func TestMp3Decode(t *testing.T) {
+	for _, sampleRate := range []int{44100, 22050} {
+		mp3, ref := testMp3(30, sampleRate, 1)
+
+		pcm, err := ReadMp3Reader(bytes.NewReader(mp3))
+		if err != nil {
+			t.Fatalf("%d Hz: %s", sampleRate, err)
+		}
+		if diff := math.Abs(float64(len(pcm)-len(ref))) / 11025; diff > 0.1 {
+			t.Errorf("%d Hz: decoded %d samples, expected %d", sampleRate, len(pcm), len(ref))
+		}
+
+		m := NewMatcher()
+		hashes := testHashes(t, pcm)
+
+		// опорный звук не повторяет гибридный банк фильтров, поэтому похожесть ниже, чем у двух декодеров между собой
+		res := m.Match(hashes, testHashes(t, ref))
+		t.Logf("%d Hz: %s", sampleRate, res)
+		if (res.Similarity < 0.15) || (math.Abs(res.OffsetInSec) > 0.1) || (res.Scale != 1) {
+			t.Errorf("%d Hz: decoded mp3 does not match source: %s", sampleRate, res)
+		}
+
+		if _, other := testMp3(30, sampleRate, 2); m.Match(hashes, testHashes(t, other)).Similarity >= res.Similarity/2 {
+			t.Errorf("%d Hz: decoded mp3 is similar to unrelated source", sampleRate)
+		}
+	}
+}
+
+// TestMp3DecodeReader проверяет, что небольшой (меньше порции чтения) MP3 с ID3 тегом перед первым фреймом
+// декодируется из потока так же, как из файла. libmad не разбирает ID3, и ложные синхрослова в теге
+// дают ему восстановимые ошибки.
+func TestMp3DecodeReader(t *testing.T) {
+	mp3, ref := testMp3(1.5, 44100, 1)
+
+	tag := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 32}
+	tag = append(tag, bytes.Repeat([]byte{0xFF, 0xFF, 0x00, 0x00}, 8)...)
+	mp3 = append(tag, mp3...)
+
+	path := filepath.Join(t.TempDir(), `junk.mp3`)
+	if err := os.WriteFile(path, mp3, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := ReadMp3(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromReader, err := ReadMp3Reader(bytes.NewReader(mp3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := math.Abs(float64(len(fromReader)-len(ref))) / 11025; diff > 0.1 {
+		t.Errorf("decoded %d samples from reader, expected %d", len(fromReader), len(ref))
+	}
+	if len(fromFile) != len(fromReader) {
+		t.Fatalf("decoded %d samples from file and %d from reader", len(fromFile), len(fromReader))
+	}
+	for i := range fromFile {
+		if fromFile[i] != fromReader[i] {
+			t.Fatalf("sample %d: %f from file, %f from reader", i, fromFile[i], fromReader[i])
+		}
+	}
+}
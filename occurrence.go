@@ -0,0 +1,148 @@
+package fennec
+
+import (
+	"math"
+	"sort"
+)
+
+type (
+	// Occurrence одно вхождение songB (клипа) в songA (длинную запись), найденное FindAll
+	Occurrence struct {
+		// Смещение songA относительно songB (позиция начала songB внутри songA) в колонках спектрограммы и в секундах
+		Offset      int
+		OffsetInSec float64
+		// Масштаб времени songA относительно songB (1 - без масштабирования)
+		Scale float64
+
+		// Число совпавших хешей в окрестности смещения и нормированная (как в MatchResult) похожесть
+		MatchedCnt int
+		Similarity float64
+
+		// Интервалы в songA и songB, покрытые совпавшими хешами, и плотность совпадений по секундам RegionA
+		RegionA TimeSpan
+		RegionB TimeSpan
+		Density []int
+	}
+
+	// offsetCount число голосов за смещение
+	offsetCount struct {
+		offset int32
+		cnt    int32
+	}
+)
+
+// FindAll находит все вхождения songB в songA (списки хешей не изменяются): каждое смещение (кластер смещений), набравшее похожесть
+// не ниже minSimilarity, дает отдельное вхождение. В отличие от Match, смещение ничем не ограничено,
+// так что songA может быть сколь угодно длинной записью (эфир, DJ микс), а songB - джинглом или треком.
+// Вхождения возвращаются по возрастанию RegionA.Start.
+func (m *Matcher) FindAll(songA Hashes, songB Hashes, minSimilarity float64) []Occurrence {
+	bufA, bufB := m.poolHashes.Get().(Hashes), m.poolHashes.Get().(Hashes)
+	preparedA, preparedB := m.prepare(songA, bufA), m.prepare(songB, bufB)
+
+	occs := m.FindAllPrepared(preparedA, preparedB, minSimilarity)
+
+	m.poolHashes.Put(preparedA.hashes[:0])
+	m.poolHashes.Put(preparedB.hashes[:0])
+
+	return occs
+}
+
+// FindAllPrepared аналог FindAll для подготовленных (см. Prepare) хешей
+func (m *Matcher) FindAllPrepared(songA, songB *PreparedHashes, minSimilarity float64) []Occurrence {
+	minLen := minInt(songA.Len(), songB.Len())
+	if minLen == 0 {
+		return nil
+	}
+
+	buf := m.poolMatches.Get().([]hashMatch)
+	matches := m.collectMatches(songA.hashes, songB.hashes, buf)
+	defer func() { m.poolMatches.Put(matches[:0]) }()
+
+	var occs []Occurrence
+	for _, scale := range m.estimateScales(matches) {
+		votes := m.voteOffsets(matches, scale, math.MaxInt32)
+
+		counts := make([]offsetCount, 0, len(votes.offs))
+		for offset, cnt := range votes.offs {
+			if cnt >= minAllowedCnt {
+				counts = append(counts, offsetCount{offset: offset, cnt: cnt})
+			}
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].cnt != counts[j].cnt {
+				return counts[i].cnt > counts[j].cnt
+			}
+			return counts[i].offset < counts[j].offset
+		})
+
+		// жадно берем самые сильные смещения вместе с окрестностью (как в voteOffsets)
+		used := make(map[int32]bool)
+		for _, oc := range counts {
+			if used[oc.offset] {
+				continue
+			}
+
+			cnt := 0
+			for i := oc.offset - offsetDistortion; i < oc.offset+offsetDistortion; i++ {
+				if o := votes.offs[i]; !used[i] && (o >= minAllowedCnt) {
+					cnt += int(o)
+					used[i] = true
+				}
+			}
+
+			sim := eq2similarity(float64(cnt) / float64(minLen))
+			if sim < minSimilarity {
+				continue
+			}
+
+			occ := Occurrence{
+				Offset:      int(oc.offset),
+				OffsetInSec: float64(oc.offset) / m.colsInOneSec,
+				Scale:       scale,
+				MatchedCnt:  cnt,
+				Similarity:  sim,
+			}
+			occ.RegionA, occ.RegionB, occ.Density = m.matchRegions(matches, votes.offs, occ.Offset, scale)
+
+			occs = append(occs, occ)
+		}
+
+		m.releaseVotes(votes)
+	}
+
+	occs = suppressOverlapping(occs)
+
+	sort.Slice(occs, func(i, j int) bool { return occs[i].RegionA.Start < occs[j].RegionA.Start })
+
+	return occs
+}
+
+// suppressOverlapping убирает вхождения, большей частью перекрывающиеся в songA с более сильными
+// (вторичные кластеры смещений того же вхождения или оно же при другом масштабе).
+// Остается вхождение с большим числом совпадений.
+func suppressOverlapping(occs []Occurrence) []Occurrence {
+	sort.Slice(occs, func(i, j int) bool { return occs[i].MatchedCnt > occs[j].MatchedCnt })
+
+	var kept []Occurrence
+	for _, occ := range occs {
+		overlaps := false
+		for _, k := range kept {
+			// касающиеся краями соседние вхождения (клип повторяется подряд) не склеиваются
+			shorter := math.Min(occ.RegionA.Duration(), k.RegionA.Duration())
+			if overlap := k.RegionA.overlap(occ.RegionA); (overlap > 0) && (overlap >= shorter/2) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, occ)
+		}
+	}
+
+	return kept
+}
+
+// overlap длина пересечения интервалов в секундах (0, если не пересекаются)
+func (s TimeSpan) overlap(o TimeSpan) float64 {
+	return math.Max(0, math.Min(s.End, o.End)-math.Max(s.Start, o.Start))
+}
@@ -0,0 +1,70 @@
+package fennec
+
+import (
+	"math"
+	"testing"
+)
+
+// testFindAll ищет клип в записи и сверяет смещения и масштабы вхождений. Вхождения не должны перекрываться.
+func testFindAll(t *testing.T, recording, clip []Float, expected []float64, scale float64) {
+	t.Helper()
+
+	occs := NewMatcher().FindAll(testHashes(t, recording), testHashes(t, clip), 0.1)
+
+	if len(occs) != len(expected) {
+		for _, occ := range occs {
+			t.Logf("%.1f scale %.3f similarity %.3f %s", occ.OffsetInSec, occ.Scale, occ.Similarity, occ.RegionA)
+		}
+		t.Fatalf("found %d occurrences, expected %d", len(occs), len(expected))
+	}
+	for i, occ := range occs {
+		if (math.Abs(occ.OffsetInSec-expected[i]) > 0.5) || (math.Abs(occ.Scale-scale) > 0.005) {
+			t.Errorf("occurrence %d at %.1f sec scale %.3f, expected %.0f sec scale %.2f", i, occ.OffsetInSec, occ.Scale, expected[i], scale)
+		}
+		if (i > 0) && (occ.RegionA.Start < occs[i-1].RegionA.End) {
+			t.Errorf("occurrence %d %s overlaps previous %s", i, occ.RegionA, occs[i-1].RegionA)
+		}
+	}
+}
+
+// testChorusClip клип с повтором (как припев), что дает вторичные смещения внутри того же вхождения
+func testChorusClip(tempo float64) []Float {
+	chorus := testMusic(8, tempo, 1)
+	var clip []Float
+	clip = append(clip, chorus...)
+	clip = append(clip, testMusic(8, tempo, 6)...)
+	return append(clip, chorus...)
+}
+
+func TestFindAllRepeated(t *testing.T) {
+	clip := testChorusClip(1)
+
+	// запись: чужое, клип, чужое, клип, клип вплотную к предыдущему, чужое
+	var recording []Float
+	recording = append(recording, testMusic(40, 1, 2)...)
+	recording = append(recording, clip...)
+	recording = append(recording, testMusic(40, 1, 3)...)
+	recording = append(recording, clip...)
+	recording = append(recording, clip...)
+	recording = append(recording, testMusic(40, 1, 4)...)
+
+	testFindAll(t, recording, clip, []float64{40, 104, 128}, 1)
+}
+
+// TestFindAllRepeatedScaled то же для ускоренных вхождений. Масштаб оценивается по всем совпадениям сразу,
+// поэтому все вхождения в записи ускорены одинаково.
+func TestFindAllRepeatedScaled(t *testing.T) {
+	const tempo = 0.96
+
+	fast := testChorusClip(tempo)
+
+	// запись: чужое, ускоренный клип, он же вплотную, чужое
+	var recording []Float
+	recording = append(recording, testMusic(40, 1, 2)...)
+	recording = append(recording, fast...)
+	recording = append(recording, fast...)
+	recording = append(recording, testMusic(40, 1, 3)...)
+
+	clipSec := float64(len(fast)) / SampleRate
+	testFindAll(t, recording, testChorusClip(1), []float64{40, 40 + clipSec}, tempo)
+}
@@ -0,0 +1,279 @@
+package fennec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+type (
+	// pcmConverter сводит декодированные каналы в моно и передискретизирует их в sampleRate.
+	// Общий для всех бэкендов декодирования, чтобы они выдавали одинаковый PCM.
+	pcmConverter struct {
+		sampleRate int
+
+		srcSampleRate int
+		rs            *resampler
+		flushed       bool
+
+		mono []int16
+	}
+)
+
+func checkReaderParams(sampleRate int, bits int) error {
+	if bits != 16 {
+		return ErrWrongParams
+	} else if sampleRate <= 0 {
+		return ErrWrongParams
+	}
+	return nil
+}
+
+// checkSrcSampleRate проверяет, что pcmConverter сможет привести srcSampleRate к sampleRate
+func checkSrcSampleRate(srcSampleRate int, sampleRate int) error {
+	if srcSampleRate <= 0 {
+		return ErrUnsupportedSampleRate
+	}
+	return nil
+}
+
+func mergeChannels(ch1, ch2 int16) int16 {
+	return int16((int32(ch1) + int32(ch2)) >> 1)
+}
+
+// convert формирует один выходной фрейм из семплов левого и правого (nil для моно) каналов.
+// buf используется как буфер под ответ.
+// Из-за задержки фильтра передискретизации длина фрейма может отличаться от пропорциональной,
+// остаток выдается через finish по окончании входа.
+func (conv *pcmConverter) convert(buf []int16, left, right []int16, srcSampleRate int) []int16 {
+	buf = buf[0:0]
+
+	if (conv.rs == nil) || (conv.srcSampleRate != srcSampleRate) {
+		if conv.rs != nil {
+			buf = conv.rs.flush(buf)
+		}
+		conv.rs = newResampler(srcSampleRate, conv.sampleRate)
+		conv.srcSampleRate = srcSampleRate
+	}
+
+	conv.downmix(left, right)
+
+	return conv.rs.process(buf, conv.mono)
+}
+
+// finish выдает остаток из фильтра передискретизации, а при повторном вызове - io.EOF
+func (conv *pcmConverter) finish(buf []int16) ([]int16, error) {
+	if (conv.rs == nil) || conv.flushed {
+		return nil, io.EOF
+	}
+	conv.flushed = true
+
+	if buf = conv.rs.flush(buf[0:0]); len(buf) == 0 {
+		return nil, io.EOF
+	}
+
+	return buf, nil
+}
+
+// downmix сводит каналы в conv.mono
+func (conv *pcmConverter) downmix(left, right []int16) {
+	srcSamplesCnt := len(left)
+	stereo := right != nil
+
+	conv.mono = conv.mono[0:0]
+
+	var (
+		mixAvg, leftAvg, rightAvg int64
+	)
+
+	for sampleIdx := 0; sampleIdx < srcSamplesCnt; sampleIdx++ {
+		sample := left[sampleIdx]
+		leftAvg += int64(sample)
+
+		if stereo {
+			sample2 := right[sampleIdx]
+			rightAvg += int64(sample2)
+			sample = mergeChannels(sample, sample2)
+		}
+
+		conv.mono = append(conv.mono, sample)
+
+		mixAvg += int64(sample)
+	}
+
+	if stereo && (srcSamplesCnt > 0) {
+		if avg := math.Abs(float64(mixAvg) / float64(srcSamplesCnt)); avg < 1 {
+			// Если среднее значение вышло ни то ни се, а отдельные L/R каналы почти что зеркально противоположны,
+			//   то просто берем в качестве результа просто один из каналов.
+			avgL := float64(leftAvg) / float64(srcSamplesCnt)
+			avgR := float64(rightAvg) / float64(srcSamplesCnt)
+			if (math.Abs(avgL+avgR) < 1) && (math.Abs(avgL) >= 1) {
+				conv.mono = append(conv.mono[0:0], left...)
+			}
+		}
+	}
+}
+
+type (
+	// RawPCMFormat описывает формат несжатого PCM (interleaved, little endian)
+	RawPCMFormat struct {
+		SampleRate int
+		Channels   int
+		// 8 (беззнаковые), 16, 24, 32 для целых семплов; 32, 64 для Float
+		BitsPerSample int
+		Float         bool
+	}
+
+	// rawPCMReader покадрово читает несжатый PCM и приводит его к моно sampleRate тем же pcmConverter, что и MP3
+	rawPCMReader struct {
+		src    io.Reader
+		closer io.Closer
+		format RawPCMFormat
+
+		frame       []byte
+		left, right []int16
+		conv        pcmConverter
+	}
+)
+
+const (
+	// сколько семплов (на канал) читается за один ReadFrame
+	rawPCMFrameSamples = 1152
+	// больше каналов не бывает в реальных файлах, а буфер фрейма растет пропорционально
+	maxChannels = 64
+)
+
+var (
+	ErrUnsupportedFormat     = errors.New(`Unsupported audio format`)
+	ErrUnsupportedSampleRate = errors.New(`Unsupported sample rate`)
+)
+
+func (f RawPCMFormat) bytesPerSample() int {
+	return f.BitsPerSample / 8
+}
+
+func (f RawPCMFormat) validate() error {
+	if (f.Channels < 1) || (f.Channels > maxChannels) || (f.SampleRate < 1) {
+		return ErrUnsupportedFormat
+	}
+
+	switch {
+	case f.Float && (f.BitsPerSample == 32 || f.BitsPerSample == 64):
+	case !f.Float && (f.BitsPerSample == 8 || f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	return nil
+}
+
+// NewRawPCMReader создает покадровый читатель несжатого PCM формата format из r
+func NewRawPCMReader(r io.Reader, format RawPCMFormat, sampleRate int, bits int) (*rawPCMReader, error) {
+	if err := checkReaderParams(sampleRate, bits); err != nil {
+		return nil, err
+	} else if err := format.validate(); err != nil {
+		return nil, err
+	} else if err := checkSrcSampleRate(format.SampleRate, sampleRate); err != nil {
+		return nil, err
+	}
+
+	rd := &rawPCMReader{
+		src:    r,
+		format: format,
+		frame:  make([]byte, rawPCMFrameSamples*format.Channels*format.bytesPerSample()),
+		conv:   pcmConverter{sampleRate: sampleRate},
+	}
+
+	return rd, nil
+}
+
+func (rd *rawPCMReader) Close() error {
+	if rd.src == nil {
+		return ErrWrongParams
+	}
+	rd.src = nil
+
+	if rd.closer != nil {
+		rd.closer.Close()
+		rd.closer = nil
+	}
+
+	return nil
+}
+
+// ReadFrame читает один PCM фрейм (см. mp3Reader.ReadFrame)
+func (rd *rawPCMReader) ReadFrame(buf []int16) ([]int16, error) {
+	channels := rd.format.Channels
+	blockAlign := channels * rd.format.bytesPerSample()
+
+	n, err := io.ReadFull(rd.src, rd.frame)
+	if (err == io.ErrUnexpectedEOF) || (err == io.EOF) {
+		if n < blockAlign {
+			return rd.conv.finish(buf)
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	samplesCnt := n / blockAlign
+
+	rd.left, rd.right = rd.left[0:0], rd.right[0:0]
+	for i := 0; i < samplesCnt; i++ {
+		block := rd.frame[i*blockAlign:]
+
+		if channels == 2 {
+			rd.left = append(rd.left, rd.sample(block, 0))
+			rd.right = append(rd.right, rd.sample(block, 1))
+			continue
+		}
+
+		// моно или многоканальный звук: просто усредняем все каналы
+		sum := 0
+		for ch := 0; ch < channels; ch++ {
+			sum += int(rd.sample(block, ch))
+		}
+		rd.left = append(rd.left, int16(sum/channels))
+	}
+
+	right := []int16(nil)
+	if channels == 2 {
+		right = rd.right
+	}
+
+	return rd.conv.convert(buf, rd.left, right, rd.format.SampleRate), nil
+}
+
+// sample приводит семпл канала ch из блока к int16
+func (rd *rawPCMReader) sample(block []byte, ch int) int16 {
+	bps := rd.format.bytesPerSample()
+	b := block[ch*bps : (ch+1)*bps]
+
+	if rd.format.Float {
+		var v float64
+		if bps == 4 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		if math.IsNaN(v) {
+			// int16(NaN) не определен, а Max/Min пропускают NaN как есть
+			return 0
+		}
+		v = math.Max(-1, math.Min(1, v))
+		return int16(math.Round(v * float64(int16ToFloat-1)))
+	}
+
+	switch bps {
+	case 1:
+		return int16((int(b[0]) - 128) << 8)
+	case 2:
+		return int16(binary.LittleEndian.Uint16(b))
+	case 3:
+		return int16(uint16(b[1]) | uint16(b[2])<<8)
+	default:
+		return int16(binary.LittleEndian.Uint16(b[2:4]))
+	}
+}
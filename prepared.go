@@ -0,0 +1,65 @@
+package fennec
+
+import (
+	"sort"
+)
+
+type (
+	// PreparedHashes подготовленные для сравнения хеши трека: отсортированная копия без хешей с очень низкими
+	// частотами и заранее посчитанное максимальное время. После создания не изменяется, поэтому один
+	// PreparedHashes можно конкурентно сравнивать с разными треками.
+	PreparedHashes struct {
+		hashes  Hashes
+		maxTime uint32
+		// длина исходного списка хешей (по ней нормируется похожесть, как и для неподготовленных хешей)
+		origLen int
+	}
+)
+
+// Prepare подготавливает хеши трека для MatchPrepared. Исходный список не изменяется.
+// Сравнивать можно только хеши, подготовленные Matcher той же версии.
+func (m *Matcher) Prepare(hashes Hashes) *PreparedHashes {
+	return m.prepare(hashes, make(Hashes, 0, len(hashes)))
+}
+
+// prepare аналог Prepare, складывающий хеши в buf[:0]
+func (m *Matcher) prepare(hashes Hashes, buf Hashes) *PreparedHashes {
+	p := &PreparedHashes{
+		hashes:  buf[:0],
+		origLen: len(hashes),
+	}
+
+	for _, h := range hashes {
+		if h.Time > p.maxTime {
+			p.maxTime = h.Time
+		}
+		if pp := m.layout.decode(h); pp.Bin1 == 0 || pp.Bin2 == 0 {
+			// хеши с очень низкими частотами пропускаем. малослышимый шум
+			continue
+		}
+		p.hashes = append(p.hashes, h)
+	}
+
+	if !sort.IsSorted(p.hashes) {
+		sort.Sort(p.hashes)
+	}
+
+	return p
+}
+
+// Len возвращает длину исходного списка хешей
+func (p *PreparedHashes) Len() int {
+	return p.origLen
+}
+
+// MaxTime возвращает максимальное время хеша трека (в колонках спектрограммы)
+func (p *PreparedHashes) MaxTime() uint32 {
+	return p.maxTime
+}
+
+// MatchPrepared сравнивает два подготовленных трека (см. Match)
+func (m *Matcher) MatchPrepared(songA, songB *PreparedHashes) MatchResult {
+	res := m.findOptimalOffset(songA, songB)
+	m.scoreResult(&res)
+	return res
+}
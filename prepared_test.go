@@ -0,0 +1,52 @@
+package fennec
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestMatchPrepared проверяет, что сравнение подготовленных хешей дает тот же результат, что и Match,
+// и что ни Prepare, ни Match не сортируют список вызывающего
+func TestMatchPrepared(t *testing.T) {
+	m := NewMatcher()
+	r := rand.New(rand.NewSource(1))
+
+	shuffled := func(hashes Hashes) Hashes {
+		r.Shuffle(len(hashes), func(i, j int) { hashes[i], hashes[j] = hashes[j], hashes[i] })
+		return hashes
+	}
+
+	track := shuffled(testHashes(t, testMusic(60, 1, 1)))
+	trackCopy := append(Hashes(nil), track...)
+	prepared := m.Prepare(track)
+
+	others := map[string]Hashes{
+		`clip`:      shuffled(testClip(testHashes(t, testMusic(60, 1, 1)), 20, 15)),
+		`stretched`: shuffled(testHashes(t, testMusic(60, 1.05, 1))),
+		`unrelated`: shuffled(testHashes(t, testMusic(60, 1, 2))),
+	}
+
+	for name, other := range others {
+		otherCopy := append(Hashes(nil), other...)
+
+		// один PreparedHashes используется во всех сравнениях
+		got := m.MatchPrepared(prepared, m.Prepare(other))
+		expected := m.Match(track, other)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: prepared %s, expected %s", name, got, expected)
+		}
+
+		if !reflect.DeepEqual(other, otherCopy) {
+			t.Errorf("%s: caller's hashes are modified", name)
+		}
+	}
+
+	if !reflect.DeepEqual(track, trackCopy) {
+		t.Error("caller's track hashes are modified")
+	}
+	if sort.IsSorted(track) {
+		t.Error("caller's track hashes are sorted")
+	}
+}
@@ -0,0 +1,153 @@
+package fennec
+
+import (
+	"math"
+
+	"github.com/mjibson/go-dsp/window"
+)
+
+const (
+	// число пересечений нуля sinc'ом в каждую сторону от центра фильтра (качество vs скорость)
+	resampleZeroCrossings = 16
+	// доля от частоты Найквиста итоговой частоты, с которой начинается подавление (запас на переходную полосу)
+	resampleRolloff = 0.9
+)
+
+type (
+	// resampler полифазный передискретизатор с фильтром windowed-sinc (окно Блэкмана).
+	// Частота меняется в up/down раз: up-кратная интерполяция нулями, ФНЧ и прореживание в down раз,
+	// но вычисляются только нужные выходные отсчеты, так что на каждый уходит taps умножений.
+	resampler struct {
+		up, down int
+		taps     int
+		// phases[p][j] коэффициент j-го входного отсчета для фазы p (уже с усилением up)
+		phases [][]float64
+		// задержка фильтра в отсчетах повышенной частоты
+		delay int64
+
+		// in хранит входные отсчеты, начиная с абсолютного индекса inStart
+		in      []float64
+		inStart int64
+		inCnt   int64
+		// индекс следующего выходного отсчета
+		outIdx int64
+	}
+)
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func newResampler(srcSampleRate, dstSampleRate int) *resampler {
+	g := gcd(srcSampleRate, dstSampleRate)
+	up, down := dstSampleRate/g, srcSampleRate/g
+
+	rs := &resampler{up: up, down: down}
+	if up == down {
+		return rs
+	}
+
+	// частота среза относительно повышенной частоты (циклов на отсчет)
+	fc := resampleRolloff * 0.5 / float64(maxInt(up, down))
+
+	rs.taps = int(math.Ceil(resampleZeroCrossings / fc / float64(up)))
+	filterLen := rs.taps * up
+	rs.delay = int64(filterLen-1) / 2
+
+	win := window.Blackman(filterLen)
+	center := float64(filterLen-1) / 2
+
+	rs.phases = make([][]float64, up)
+	for p := range rs.phases {
+		rs.phases[p] = make([]float64, rs.taps)
+	}
+
+	for k := 0; k < filterLen; k++ {
+		x := float64(k) - center
+		h := 2 * fc
+		if x != 0 {
+			h = math.Sin(2*math.Pi*fc*x) / (math.Pi * x)
+		}
+		rs.phases[k%up][k/up] = h * win[k] * float64(up)
+	}
+
+	return rs
+}
+
+// process передискретизирует очередную порцию семплов и дописывает результат в buf
+func (rs *resampler) process(buf []int16, samples []int16) []int16 {
+	if rs.up == rs.down {
+		return append(buf, samples...)
+	}
+
+	for _, s := range samples {
+		rs.in = append(rs.in, float64(s))
+	}
+	rs.inCnt += int64(len(samples))
+
+	return rs.produce(buf, false)
+}
+
+// flush выдает хвост, оставшийся в фильтре, считая дальнейший вход нулевым
+func (rs *resampler) flush(buf []int16) []int16 {
+	if rs.up == rs.down {
+		return buf
+	}
+	return rs.produce(buf, true)
+}
+
+func (rs *resampler) produce(buf []int16, final bool) []int16 {
+	up, down := int64(rs.up), int64(rs.down)
+
+	// сколько всего выходных отсчетов соответствует уже поступившему входу
+	outTotal := (rs.inCnt*up + down - 1) / down
+
+	for rs.outIdx < outTotal {
+		t := rs.outIdx*down + rs.delay
+		phase, last := t%up, t/up
+
+		if !final && (last >= rs.inCnt) {
+			break
+		}
+
+		sum := float64(0)
+		for j, k := range rs.phases[phase] {
+			idx := last - int64(j) - rs.inStart
+			if idx < 0 {
+				break
+			} else if idx < int64(len(rs.in)) {
+				sum += k * rs.in[idx]
+			}
+		}
+
+		buf = append(buf, int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(sum)))))
+		rs.outIdx++
+	}
+
+	// отбрасываем вход, который уже не понадобится следующим выходным отсчетам
+	nextLast := (rs.outIdx*down + rs.delay) / up
+	if drop := nextLast - int64(rs.taps) + 1 - rs.inStart; drop > 0 {
+		drop = minInt64(drop, int64(len(rs.in)))
+		rs.in = append(rs.in[:0], rs.in[drop:]...)
+		rs.inStart += drop
+	}
+
+	return buf
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
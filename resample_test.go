@@ -0,0 +1,94 @@
+package fennec
+
+import (
+	"math"
+	"testing"
+)
+
+// testSine синусоида частоты freq и амплитуды amp (в долях полной шкалы) длиной sec секунд
+func testSine(sampleRate int, freq, amp, sec float64) []int16 {
+	samples := make([]int16, int(sec*float64(sampleRate)))
+	for i := range samples {
+		samples[i] = int16(math.Round(amp * float64(math.MaxInt16) * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))))
+	}
+	return samples
+}
+
+// testResample передискретизирует samples порциями длиной chunks[i] (по кругу) и сбрасывает хвост фильтра
+func testResample(from, to int, samples []int16, chunks ...int) []int16 {
+	rs := newResampler(from, to)
+
+	var out []int16
+	for i := 0; len(samples) > 0; i++ {
+		n := len(samples)
+		if len(chunks) > 0 {
+			n = minInt(n, chunks[i%len(chunks)])
+		}
+		out = rs.process(out, samples[:n])
+		samples = samples[n:]
+	}
+
+	return rs.flush(out)
+}
+
+// testPeak максимальная амплитуда (в долях полной шкалы) середины сигнала, без переходных процессов по краям
+func testPeak(samples []int16) float64 {
+	edge := len(samples) / 10
+	peak := 0.0
+	for _, v := range samples[edge : len(samples)-edge] {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	return peak / math.MaxInt16
+}
+
+func TestResampleLength(t *testing.T) {
+	for _, from := range []int{48000, 32000} {
+		in := testSine(from, 440, 0.5, 3)
+		out := testResample(from, SampleRate, in)
+
+		expected := float64(len(in)) * SampleRate / float64(from)
+		if math.Abs(float64(len(out))-expected) > 1 {
+			t.Errorf("%d -> %d: %d samples, expected %.1f", from, SampleRate, len(out), expected)
+		}
+	}
+}
+
+func TestResampleFrequencyResponse(t *testing.T) {
+	const amp = 0.5
+
+	for _, from := range []int{48000, 32000} {
+		// ниже частоты среза (0.9 от новой частоты Найквиста) амплитуда сохраняется
+		for _, freq := range []float64{100, 1000, 4000} {
+			peak := testPeak(testResample(from, SampleRate, testSine(from, freq, amp, 1)))
+			if math.Abs(peak-amp) > 0.01 {
+				t.Errorf("%d Hz from %d: amplitude %.4f, expected %.4f", int(freq), from, peak, amp)
+			}
+		}
+
+		// выше новой частоты Найквиста тон подавляется, а не заворачивается в полосу
+		for _, freq := range []float64{6500, 9000, 15000} {
+			if float64(from)/2 <= freq {
+				continue
+			}
+			peak := testPeak(testResample(from, SampleRate, testSine(from, freq, amp, 1)))
+			if peak > amp/100 {
+				t.Errorf("%d Hz from %d: amplitude %.4f is not attenuated", int(freq), from, peak)
+			}
+		}
+	}
+}
+
+func TestResampleChunked(t *testing.T) {
+	in := testSine(44100, 1000, 0.5, 2)
+	whole := testResample(44100, SampleRate, in)
+	chunked := testResample(44100, SampleRate, in, 1, 1152, 7, 4000, 333)
+
+	if len(whole) != len(chunked) {
+		t.Fatalf("%d samples in one call, %d in chunks", len(whole), len(chunked))
+	}
+	for i := range whole {
+		if whole[i] != chunked[i] {
+			t.Fatalf("sample %d: %d in one call, %d in chunks", i, whole[i], chunked[i])
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package fennec
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// Формат сегмента индекса (little endian):
+//
+//	header   segmentHeaderSize байт: magic, версия формата, диапазон id сегментов, число треков и постингов,
+//	         версия параметров отпечатков (FingerprintVersion) и число бит хеша
+//	tracks   tracksCnt x (TrackID uint32, число хешей uint32), по возрастанию TrackID
+//	dir      (1<<segmentDirBits)+1 x uint32: индекс первого постинга для каждого старшего куска хеша
+//	postings postingsCnt x (hash uint32, TrackID uint32, time uint32), по возрастанию (hash, TrackID, time)
+//
+// Сегмент неизменяем после записи и читается через mmap.
+
+const (
+	segmentMagic   = "FNSG"
+	segmentVersion = 2
+
+	segmentHeaderSize  = 48
+	segmentTrackSize   = 8
+	segmentPostingSize = 12
+
+	// число старших бит хеша, по которым строится директория сегмента
+	segmentDirBits = 12
+	segmentDirSize = (1 << segmentDirBits) + 1
+
+	segmentFileExt = `.fnseg`
+)
+
+var (
+	ErrBadSegment = errors.New(`Bad index segment`)
+	// ErrSegmentVersion сегмент записан прежней версией формата; индекс нужно построить заново
+	ErrSegmentVersion = errors.New(`Index segment format is outdated, rebuild required`)
+)
+
+type (
+	// segment один mmap'нутый файл индекса
+	segment struct {
+		path string
+		// диапазон id исходных сегментов, из которых собран этот (при слиянии)
+		first, last uint64
+
+		data     []byte
+		tracks   []byte
+		dir      []byte
+		postings []byte
+
+		tracksCnt   int
+		postingsCnt int
+
+		segmentParams
+	}
+
+	// segmentParams параметры отпечатков, хеши которых лежат в сегменте
+	segmentParams struct {
+		version FingerprintVersion
+		// число бит хеша (см. hashLayout)
+		hashBits uint
+	}
+
+	segmentPosting struct {
+		hash uint32
+		posting
+	}
+
+	segmentTrack struct {
+		id  TrackID
+		cnt uint32
+	}
+
+	// segmentCursor позиция в сегменте при слиянии
+	segmentCursor struct {
+		seg *segment
+		pos int
+		cur segmentPosting
+	}
+
+	// segmentCursors сортирует по возрастанию текущего постинга (min-heap для слияния)
+	segmentCursors []*segmentCursor
+)
+
+func (c segmentCursors) Len() int            { return len(c) }
+func (c segmentCursors) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c segmentCursors) Less(i, j int) bool  { return c[i].cur.less(c[j].cur) }
+func (c *segmentCursors) Push(x interface{}) { *c = append(*c, x.(*segmentCursor)) }
+func (c *segmentCursors) Pop() interface{} {
+	old := *c
+	x := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return x
+}
+
+func (p segmentPosting) less(o segmentPosting) bool {
+	if p.hash != o.hash {
+		return p.hash < o.hash
+	} else if p.Track != o.Track {
+		return p.Track < o.Track
+	}
+	return p.Time < o.Time
+}
+
+func newSegmentParams(version FingerprintVersion, layout hashLayout) segmentParams {
+	return segmentParams{
+		version:  version,
+		hashBits: layout.binBits + layout.binDiffBits + layout.timeDiffBits,
+	}
+}
+
+// dirShift сдвиг хеша, дающий его бакет в директории сегмента
+func (p segmentParams) dirShift() uint {
+	if p.hashBits <= segmentDirBits {
+		return 0
+	}
+	return p.hashBits - segmentDirBits
+}
+
+func segmentFileName(first, last uint64) string {
+	return fmt.Sprintf(`seg-%016x-%016x%s`, first, last, segmentFileExt)
+}
+
+func openSegment(path string) (*segment, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := fi.Size()
+	if size < segmentHeaderSize {
+		return nil, ErrBadSegment
+	}
+
+	data, err := syscall.Mmap(int(fd.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, ErrMmapFail
+	}
+
+	seg := &segment{path: path, data: data}
+	if err := seg.parse(); err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	return seg, nil
+}
+
+func (seg *segment) parse() error {
+	data := seg.data
+	le := binary.LittleEndian
+
+	if string(data[0:4]) != segmentMagic {
+		return ErrBadSegment
+	} else if le.Uint32(data[4:8]) != segmentVersion {
+		return ErrSegmentVersion
+	}
+
+	seg.first = le.Uint64(data[8:16])
+	seg.last = le.Uint64(data[16:24])
+	seg.tracksCnt = int(le.Uint32(data[24:28]))
+	seg.postingsCnt = int(le.Uint64(data[28:36]))
+	seg.version = FingerprintVersion(le.Uint32(data[36:40]))
+	seg.hashBits = uint(le.Uint32(data[40:44]))
+
+	tracksEnd := segmentHeaderSize + seg.tracksCnt*segmentTrackSize
+	dirEnd := tracksEnd + segmentDirSize*4
+	postingsEnd := dirEnd + seg.postingsCnt*segmentPostingSize
+
+	if (seg.tracksCnt < 0) || (seg.postingsCnt < 0) || (postingsEnd != len(data)) || (seg.hashBits > 32) {
+		return ErrBadSegment
+	}
+
+	seg.tracks = data[segmentHeaderSize:tracksEnd]
+	seg.dir = data[tracksEnd:dirEnd]
+	seg.postings = data[dirEnd:postingsEnd]
+
+	// директория должна быть монотонной и ссылаться только внутрь postings
+	prev := 0
+	for i := 0; i < segmentDirSize; i++ {
+		offs := seg.dirEntry(i)
+		if (offs < prev) || (offs > seg.postingsCnt) {
+			return ErrBadSegment
+		}
+		prev = offs
+	}
+	if prev != seg.postingsCnt {
+		return ErrBadSegment
+	}
+
+	return nil
+}
+
+func (seg *segment) close() error {
+	if seg.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(seg.data)
+	seg.data, seg.tracks, seg.dir, seg.postings = nil, nil, nil, nil
+	return err
+}
+
+func (seg *segment) track(i int) segmentTrack {
+	b := seg.tracks[i*segmentTrackSize:]
+	return segmentTrack{
+		id:  TrackID(binary.LittleEndian.Uint32(b[0:4])),
+		cnt: binary.LittleEndian.Uint32(b[4:8]),
+	}
+}
+
+func (seg *segment) posting(i int) segmentPosting {
+	b := seg.postings[i*segmentPostingSize:]
+	return segmentPosting{
+		hash: binary.LittleEndian.Uint32(b[0:4]),
+		posting: posting{
+			Track: TrackID(binary.LittleEndian.Uint32(b[4:8])),
+			Time:  binary.LittleEndian.Uint32(b[8:12]),
+		},
+	}
+}
+
+func (seg *segment) dirEntry(i int) int {
+	return int(binary.LittleEndian.Uint32(seg.dir[i*4:]))
+}
+
+func (seg *segment) trackLen(trackID TrackID) int {
+	i := sort.Search(seg.tracksCnt, func(i int) bool { return seg.track(i).id >= trackID })
+	if i < seg.tracksCnt {
+		if t := seg.track(i); t.id == trackID {
+			return int(t.cnt)
+		}
+	}
+	return 0
+}
+
+func (seg *segment) lookup(hash uint32, fn func(p posting)) {
+	if (uint64(hash) >> seg.hashBits) != 0 {
+		return
+	}
+
+	bucket := int(hash >> seg.dirShift())
+	from, to := seg.dirEntry(bucket), seg.dirEntry(bucket+1)
+
+	i := from + sort.Search(to-from, func(i int) bool { return seg.posting(from+i).hash >= hash })
+	for ; i < to; i++ {
+		p := seg.posting(i)
+		if p.hash != hash {
+			break
+		}
+		fn(p.posting)
+	}
+}
+
+// writeSegment атомарно (через временный файл) записывает сегмент в dir.
+// postings должны выдаваться функцией next по возрастанию, dirCounts - число постингов в каждом бакете директории.
+func writeSegment(
+	dir string, first, last uint64, params segmentParams, tracks []segmentTrack, dirCounts []int,
+	next func() (segmentPosting, bool),
+) (path string, err error) {
+	path = filepath.Join(dir, segmentFileName(first, last))
+
+	fd, err := os.CreateTemp(dir, `tmp-seg-*`)
+	if err != nil {
+		return ``, err
+	}
+	defer func() {
+		if err != nil {
+			fd.Close()
+			os.Remove(fd.Name())
+		}
+	}()
+
+	postingsCnt := 0
+	for _, cnt := range dirCounts {
+		postingsCnt += cnt
+	}
+
+	w := bufio.NewWriter(fd)
+	le := binary.LittleEndian
+	var buf [segmentHeaderSize]byte
+
+	copy(buf[0:4], segmentMagic)
+	le.PutUint32(buf[4:8], segmentVersion)
+	le.PutUint64(buf[8:16], first)
+	le.PutUint64(buf[16:24], last)
+	le.PutUint32(buf[24:28], uint32(len(tracks)))
+	le.PutUint64(buf[28:36], uint64(postingsCnt))
+	le.PutUint32(buf[36:40], uint32(params.version))
+	le.PutUint32(buf[40:44], uint32(params.hashBits))
+	le.PutUint32(buf[44:48], 0)
+	w.Write(buf[:segmentHeaderSize])
+
+	for _, t := range tracks {
+		le.PutUint32(buf[0:4], uint32(t.id))
+		le.PutUint32(buf[4:8], t.cnt)
+		w.Write(buf[:segmentTrackSize])
+	}
+
+	offs := 0
+	for i := 0; i < segmentDirSize; i++ {
+		le.PutUint32(buf[0:4], uint32(offs))
+		w.Write(buf[:4])
+		if i < len(dirCounts) {
+			offs += dirCounts[i]
+		}
+	}
+
+	written := 0
+	for p, ok := next(); ok; p, ok = next() {
+		le.PutUint32(buf[0:4], p.hash)
+		le.PutUint32(buf[4:8], uint32(p.Track))
+		le.PutUint32(buf[8:12], p.Time)
+		if _, err = w.Write(buf[:segmentPostingSize]); err != nil {
+			return ``, err
+		}
+		written++
+	}
+
+	if written != postingsCnt {
+		return ``, io.ErrShortWrite
+	}
+
+	if err = w.Flush(); err != nil {
+		return ``, err
+	} else if err = fd.Sync(); err != nil {
+		return ``, err
+	} else if err = fd.Close(); err != nil {
+		return ``, err
+	}
+
+	if err = os.Rename(fd.Name(), path); err != nil {
+		return ``, err
+	}
+
+	return path, nil
+}
+
+// writeSegmentFromIndex сбрасывает содержимое in-memory индекса в новый сегмент
+func writeSegmentFromIndex(dir string, id uint64, idx *Index) (string, error) {
+	tracks := make([]segmentTrack, 0, len(idx.lens))
+	for trackID, cnt := range idx.lens {
+		tracks = append(tracks, segmentTrack{id: trackID, cnt: uint32(cnt)})
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].id < tracks[j].id })
+
+	params := newSegmentParams(idx.matcher.version, idx.matcher.layout)
+	dirShift := params.dirShift()
+
+	hashes := make([]uint32, 0, len(idx.postings))
+	dirCounts := make([]int, segmentDirSize-1)
+	for hash, postings := range idx.postings {
+		hashes = append(hashes, hash)
+		dirCounts[hash>>dirShift] += len(postings)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	hashIdx, postIdx := 0, 0
+	var postings []posting
+
+	next := func() (segmentPosting, bool) {
+		for postIdx >= len(postings) {
+			if hashIdx >= len(hashes) {
+				return segmentPosting{}, false
+			}
+			// копия, т.к. idx параллельно читается запросами
+			postings = append(postings[:0], idx.postings[hashes[hashIdx]]...)
+			sort.Slice(postings, func(i, j int) bool {
+				return (postings[i].Track < postings[j].Track) ||
+					(postings[i].Track == postings[j].Track && postings[i].Time < postings[j].Time)
+			})
+			hashIdx++
+			postIdx = 0
+		}
+
+		p := segmentPosting{hash: hashes[hashIdx-1], posting: postings[postIdx]}
+		postIdx++
+		return p, true
+	}
+
+	return writeSegment(dir, id, id, params, tracks, dirCounts, next)
+}
+
+// mergeSegments сливает несколько сегментов (одной версии) в один новый.
+// Постинги треков, для которых drop (если задана) возвращает true, в новый сегмент не попадают.
+func mergeSegments(dir string, segs []*segment, drop func(seg *segment, trackID TrackID) bool) (string, error) {
+	first, last := segs[0].first, segs[0].last
+	params := segs[0].segmentParams
+
+	lens := make(map[TrackID]uint32)
+	dirCounts := make([]int, segmentDirSize-1)
+	cursors := make(segmentCursors, 0, len(segs))
+
+	for _, seg := range segs {
+		if err := checkVersion(params.version, seg.version); err != nil {
+			return ``, err
+		}
+
+		if seg.first < first {
+			first = seg.first
+		}
+		if seg.last > last {
+			last = seg.last
+		}
+
+		hasDropped := false
+		for i := 0; i < seg.tracksCnt; i++ {
+			t := seg.track(i)
+			if (drop != nil) && drop(seg, t.id) {
+				hasDropped = true
+				continue
+			}
+			lens[t.id] += t.cnt
+		}
+
+		for i := range dirCounts {
+			dirCounts[i] += seg.dirEntry(i+1) - seg.dirEntry(i)
+		}
+
+		if hasDropped {
+			// бакеты директории должны точно соответствовать записанным постингам
+			dirShift := seg.dirShift()
+			for i := 0; i < seg.postingsCnt; i++ {
+				if p := seg.posting(i); drop(seg, p.Track) {
+					dirCounts[p.hash>>dirShift]--
+				}
+			}
+		}
+
+		if seg.postingsCnt > 0 {
+			cursors = append(cursors, &segmentCursor{seg: seg, cur: seg.posting(0)})
+		}
+	}
+
+	tracks := make([]segmentTrack, 0, len(lens))
+	for id, cnt := range lens {
+		tracks = append(tracks, segmentTrack{id: id, cnt: cnt})
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].id < tracks[j].id })
+
+	heap.Init(&cursors)
+
+	next := func() (segmentPosting, bool) {
+		for len(cursors) > 0 {
+			c := cursors[0]
+			p, seg := c.cur, c.seg
+			if c.pos++; c.pos < c.seg.postingsCnt {
+				c.cur = c.seg.posting(c.pos)
+				heap.Fix(&cursors, 0)
+			} else {
+				heap.Pop(&cursors)
+			}
+
+			if (drop == nil) || !drop(seg, p.Track) {
+				return p, true
+			}
+		}
+		return segmentPosting{}, false
+	}
+
+	return writeSegment(dir, first, last, params, tracks, dirCounts, next)
+}
@@ -10,6 +10,10 @@ type (
 	// HashesByTime сортирует по возрастанию времени, а при равных - по возрастанию Hash.TimeDiff
 	HashesByTime []Hash
 
+	// hashesByTimeHash сортирует по возрастанию времени, а при равных - по возрастанию значения Hash
+	// (не зависит от раскладки хеша, в отличие от HashesByTime)
+	hashesByTimeHash []Hash
+
 	ValIdx struct {
 		Val uint32
 		Idx uint32
@@ -40,6 +44,12 @@ func (h HashesByTime) Less(i, j int) bool {
 	return h[i].ToPeakPair().TimeDiff < h[j].ToPeakPair().TimeDiff
 }
 
+func (h hashesByTimeHash) Len() int      { return len(h) }
+func (h hashesByTimeHash) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h hashesByTimeHash) Less(i, j int) bool {
+	return (h[i].Time < h[j].Time) || (h[i].Time == h[j].Time && h[i].Hash < h[j].Hash)
+}
+
 func (a ValIdxs) Len() int      { return len(a) }
 func (a ValIdxs) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ValIdxs) Less(i, j int) bool {
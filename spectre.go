@@ -1,8 +1,8 @@
 package fennec
 
 import (
+	"errors"
 	"github.com/mjibson/go-dsp/fft"
-	"github.com/mjibson/go-dsp/window"
 	"math"
 	"math/cmplx"
 	"sort"
@@ -50,6 +50,13 @@ const (
 	maxTimeMsDiffForTracksCompare = 10 * 60 * 1000
 )
 
+var (
+	// ErrSilentInput входной звук пустой (тишина), построить по нему отпечаток нельзя
+	ErrSilentInput = errors.New(`Silent input`)
+	// ErrTooShort входной звук короче одного окна FFT
+	ErrTooShort = errors.New(`Input is too short`)
+)
+
 var (
 	// коэффициент затухания огибающей пиков (findPeaksInSpectre)
 	decayingKoeff = 0.98
@@ -87,14 +94,9 @@ type (
 	}
 )
 
-var (
-	gaussian Gaussian
-)
-
-// Сколько колонок (элементов []Hash) в одной секунде трека
+// Сколько колонок (элементов []Hash) в одной секунде трека (для DefaultConfig)
 func HashColsInOneSec() float64 {
-	// Пока захардкодил единственные параметры
-	return float64(SampleRate) / float64(FFTWinSize/2)
+	return DefaultConfig.HashColsInOneSec()
 }
 
 func NewPeakPair(time1, bin1, time2, bin2 uint) PeakPair {
@@ -106,37 +108,18 @@ func NewPeakPair(time1, bin1, time2, bin2 uint) PeakPair {
 	}
 }
 
+// ToHash упаковывает пару в хеш с раскладкой DefaultConfig
 func (pp PeakPair) ToHash() uint32 {
-	bin1 := pp.Bin1 & binMask
-	binDiff := (pp.Bin2 - pp.Bin1) & binDiffMask
-	timeDiff := pp.TimeDiff & timeDiffMask
-
-	hash := (((bin1 << binDiffBits) | binDiff) << timeDiffBits) | timeDiff
-	return uint32(hash)
+	return defaultLayout.encode(pp)
 }
 
 func (pp PeakPair) Time2() uint {
 	return pp.Time1 + pp.TimeDiff
 }
 
+// ToPeakPair распаковывает хеш с раскладкой DefaultConfig
 func (h Hash) ToPeakPair() PeakPair {
-	timeDiff := uint(h.Hash & timeDiffMask)
-	binDiff := uint((h.Hash >> timeDiffBits) & binDiffMask)
-	bin1 := uint(((h.Hash >> timeDiffBits) >> binDiffBits) & binMask)
-
-	d := int(binDiff)
-	if (d & ((binDiffMask + 1) >> 1)) > 0 {
-		d = -((binDiffMask + 1) - d)
-	}
-
-	bin2 := uint(d + int(bin1))
-
-	return PeakPair{
-		Time1:    uint(h.Time),
-		Bin1:     bin1,
-		Bin2:     bin2,
-		TimeDiff: timeDiff,
-	}
+	return defaultLayout.decode(h)
 }
 
 func (g *Gaussian) Make(n int, width float64) []float64 {
@@ -219,24 +202,29 @@ func fading(vec []Float, factor Float) {
 	}
 }
 
-func buildSpectre(wave []Float) (spectre [][]Float) {
+func buildSpectre(wave []Float) (spectre [][]Float, err error) {
+	return defaultFingerprinter.buildSpectre(wave)
+}
+
+func (fp *Fingerprinter) buildSpectre(wave []Float) (spectre [][]Float, err error) {
+	winSize := fp.cfg.FFTWinSize
+	halfWinSize := winSize / 2
+
 	waveLen := len(wave)
-	if waveLen == 0 {
-		return
+	if waveLen < winSize {
+		return nil, ErrTooShort
 	}
 
-	winFunc := window.Hann(FFTWinSize + 2)[1 : FFTWinSize+1]
+	spectre = make([][]Float, halfWinSize+1) // rows x cols
 
-	spectre = make([][]Float, FFTHalfWinSize+1) // rows x cols
+	win := make([]float64, winSize)
+	winZeroes := make([]float64, winSize)
 
-	win := make([]float64, FFTWinSize)
-	winZeroes := make([]float64, FFTWinSize)
-
-	stride := FFTWinSize - FFTOverlap
+	stride := winSize - fp.cfg.FFTOverlap
 	winCnt := (waveLen + stride - 1) / stride
 	for winIdx, offs := 0, 0; winIdx < winCnt; winIdx, offs = winIdx+1, offs+stride {
-		idx := minInt(waveLen, offs+FFTWinSize)
-		if idx < (offs + FFTWinSize) {
+		idx := minInt(waveLen, offs+winSize)
+		if idx < (offs + winSize) {
 			copy(win, winZeroes)
 		}
 
@@ -244,16 +232,16 @@ func buildSpectre(wave []Float) (spectre [][]Float) {
 			win[i-offs] = float64(wave[i])
 		}
 
-		for i, w := range winFunc {
+		for i, w := range fp.winFunc {
 			win[i] *= w
 		}
 
 		line := fft.FFTReal(win)
-		for i := 0; i < FFTHalfWinSize+1; i++ {
+		for i := 0; i < halfWinSize+1; i++ {
 			win[i] = cmplx.Abs(line[i])
 		}
 
-		for i, mag := range win[0 : FFTHalfWinSize+1] {
+		for i, mag := range win[0 : halfWinSize+1] {
 			spectre[i] = append(spectre[i], Float(mag))
 		}
 	}
@@ -267,7 +255,7 @@ func buildSpectre(wave []Float) (spectre [][]Float) {
 	}
 
 	if spectreMax < 1e-6 {
-		panic(`Zero signal`)
+		return nil, ErrSilentInput
 	}
 
 	minMag := spectreMax / 1e6
@@ -296,12 +284,23 @@ func buildSpectre(wave []Float) (spectre [][]Float) {
 
 	spectre = spectre[:len(spectre)-1]
 
-	return
+	return spectre, nil
 }
 
 func findPeaksInSpectre(spectre [][]Float) (peakList []Peak) {
-	peaks := scanForPeaks(spectre, Float(decayingKoeff))
-	peaks = filterPeaks(spectre, peaks, Float(decayingKoeff))
+	return defaultFingerprinter.findPeaksInSpectre(spectre)
+}
+
+func (fp *Fingerprinter) findPeaksInSpectre(spectre [][]Float) (peakList []Peak) {
+	if (len(spectre) < 2) || (len(spectre[0]) == 0) {
+		return nil
+	}
+
+	// кеш гауссиан на время одного построения (Gaussian не потокобезопасен)
+	var gaus Gaussian
+
+	peaks := fp.scanForPeaks(spectre, &gaus)
+	peaks = fp.filterPeaks(spectre, peaks, &gaus)
 
 	srows, scols := len(spectre), len(spectre[0])
 	for x := 0; x < scols; x++ {
@@ -315,16 +314,21 @@ func findPeaksInSpectre(spectre [][]Float) (peakList []Peak) {
 	return
 }
 
-func findPeaks(wave []Float) (peakList []Peak, spectre [][]Float) {
-	spectre = buildSpectre(wave)
-	if len(spectre) == 0 {
-		return
+func findPeaks(wave []Float) (peakList []Peak, spectre [][]Float, err error) {
+	return defaultFingerprinter.findPeaks(wave)
+}
+
+func (fp *Fingerprinter) findPeaks(wave []Float) (peakList []Peak, spectre [][]Float, err error) {
+	if spectre, err = fp.buildSpectre(wave); err != nil {
+		return nil, nil, err
 	}
 
-	return findPeaksInSpectre(spectre), spectre
+	return fp.findPeaksInSpectre(spectre), spectre, nil
 }
 
-func scanForPeaks(spectre [][]Float, shadingCoeff Float) [][]int {
+func (fp *Fingerprinter) scanForPeaks(spectre [][]Float, gaus *Gaussian) [][]int {
+	width := fp.cfg.GaussianWidth
+
 	numRows, numCols := len(spectre), len(spectre[0])
 
 	scolsThresh := minInt(10, numCols)
@@ -336,7 +340,7 @@ func scanForPeaks(spectre [][]Float, shadingCoeff Float) [][]int {
 
 	maximumInLines := maxPerLine(lines)
 
-	thresh := spreadPeaksInVector(maximumInLines, gaussianWidth)
+	thresh := spreadPeaksInVector(maximumInLines, width, gaus)
 
 	peaks := make([][]int, numRows)
 	for y := range spectre {
@@ -350,47 +354,50 @@ func scanForPeaks(spectre [][]Float, shadingCoeff Float) [][]int {
 			scol[y] = spectre[y][col]
 		}
 
-		var peaksPositions []int
-		for i, isLocMax := range locMax(scol) {
-			if isLocMax && (scol[i] > thresh[i]) {
-				peaksPositions = append(peaksPositions, i)
-			}
+		var valsPeaks PeakSpectrSlice
+		thresh, valsPeaks = fp.scanColumn(scol, thresh, gaus)
+		for _, valsPeak := range valsPeaks {
+			peaks[valsPeak.Idx][col] = 1
 		}
+	}
 
-		if len(peaksPositions) > 0 {
-			var valsPeaks PeakSpectrSlice
-			for _, idx := range peaksPositions {
-				valsPeaks = append(valsPeaks, PeakSpectr{Idx: uint(idx), Val: scol[idx]})
-			}
+	return peaks
+}
 
-			sort.Sort(valsPeaks)
+// scanColumn один шаг прямого прохода scanForPeaks: выбирает локальные максимумы колонки scol выше порога thresh
+// (не более MaxPeaksPerFrame самых сильных), расширяет ими порог и применяет затухание
+func (fp *Fingerprinter) scanColumn(scol []Float, thresh []Float, gaus *Gaussian) ([]Float, PeakSpectrSlice) {
+	var valsPeaks PeakSpectrSlice
+	for i, isLocMax := range locMax(scol) {
+		if isLocMax && (scol[i] > thresh[i]) {
+			valsPeaks = append(valsPeaks, PeakSpectr{Idx: uint(i), Val: scol[i]})
+		}
+	}
 
-			if len(valsPeaks) > maxPeaksPerFrame {
-				valsPeaks = valsPeaks[0:maxPeaksPerFrame]
-			}
-			for _, valsPeak := range valsPeaks {
-				peakPos := valsPeak.Idx
-				peak := PeakSpectr{Idx: peakPos, Val: scol[peakPos]}
-				thresh = spreadPeaks([]PeakSpectr{peak}, 0, gaussianWidth, thresh)
+	if len(valsPeaks) > 0 {
+		sort.Sort(valsPeaks)
 
-				peaks[peakPos][col] = 1
-			}
+		if len(valsPeaks) > fp.cfg.MaxPeaksPerFrame {
+			valsPeaks = valsPeaks[0:fp.cfg.MaxPeaksPerFrame]
+		}
+		for _, peak := range valsPeaks {
+			thresh = spreadPeaks([]PeakSpectr{peak}, 0, fp.cfg.GaussianWidth, thresh, gaus)
 		}
-
-		fading(thresh, Float(shadingCoeff))
 	}
 
-	return peaks
+	fading(thresh, Float(fp.cfg.DecayingKoeff))
+
+	return thresh, valsPeaks
 }
 
-func filterPeaks(spectre [][]Float, peaks [][]int, shadingCoeff Float) [][]int {
+func (fp *Fingerprinter) filterPeaks(spectre [][]Float, peaks [][]int, gaus *Gaussian) [][]int {
 	numRows, numCols := len(spectre), len(spectre[0])
 
 	lastCol := make([]Float, numRows)
 	for y := 0; y < numRows; y++ {
 		lastCol[y] = spectre[y][numCols-1]
 	}
-	thresh := spreadPeaksInVector(lastCol, gaussianWidth)
+	thresh := spreadPeaksInVector(lastCol, fp.cfg.GaussianWidth, gaus)
 
 	for col := numCols; col > 0; col-- {
 		var colPeaks PeakSpectrSlice
@@ -400,11 +407,11 @@ func filterPeaks(spectre [][]Float, peaks [][]int, shadingCoeff Float) [][]int {
 			}
 		}
 
-		sort.Sort(colPeaks)
+		var passed []bool
+		thresh, passed = fp.filterColumn(colPeaks, thresh, gaus)
 
-		for _, peak := range colPeaks {
-			if peak.Val > thresh[peak.Idx] {
-				thresh = spreadPeaks([]PeakSpectr{peak}, 0, gaussianWidth, thresh)
+		for i, peak := range colPeaks {
+			if passed[i] {
 				if col < numCols {
 					peaks[peak.Idx][col] = 0
 				}
@@ -412,13 +419,29 @@ func filterPeaks(spectre [][]Float, peaks [][]int, shadingCoeff Float) [][]int {
 				peaks[peak.Idx][col-1] = 0
 			}
 		}
-
-		fading(thresh, shadingCoeff)
 	}
 
 	return peaks
 }
 
+// filterColumn один шаг обратного прохода filterPeaks: пики колонки colPeaks (сортируются по убыванию Val),
+// превысившие порог thresh, расширяют его и отмечаются в passed, затем к порогу применяется затухание
+func (fp *Fingerprinter) filterColumn(colPeaks PeakSpectrSlice, thresh []Float, gaus *Gaussian) (_ []Float, passed []bool) {
+	sort.Sort(colPeaks)
+
+	passed = make([]bool, len(colPeaks))
+	for i, peak := range colPeaks {
+		if peak.Val > thresh[peak.Idx] {
+			thresh = spreadPeaks([]PeakSpectr{peak}, 0, fp.cfg.GaussianWidth, thresh, gaus)
+			passed[i] = true
+		}
+	}
+
+	fading(thresh, Float(fp.cfg.DecayingKoeff))
+
+	return thresh, passed
+}
+
 func locMaxIndices(vec []Float) []int {
 	neighbours := locMax(vec)
 
@@ -445,17 +468,17 @@ func locMax(vec []Float) []bool {
 	return neighbours
 }
 
-func spreadPeaksInVector(vector []Float, width float64) []Float {
+func spreadPeaksInVector(vector []Float, width float64, gaus *Gaussian) []Float {
 	var peaks []PeakSpectr
 
 	for _, idx := range locMaxIndices(vector) {
 		peaks = append(peaks, PeakSpectr{Idx: uint(idx), Val: vector[idx]})
 	}
 
-	return spreadPeaks(peaks, len(vector), width, nil)
+	return spreadPeaks(peaks, len(vector), width, nil, gaus)
 }
 
-func spreadPeaks(peaks []PeakSpectr, numPoints int, width float64, base []Float) []Float {
+func spreadPeaks(peaks []PeakSpectr, numPoints int, width float64, base []Float, gaussian *Gaussian) []Float {
 	if base != nil {
 		numPoints = len(base)
 	}
@@ -478,11 +501,21 @@ func spreadPeaks(peaks []PeakSpectr, numPoints int, width float64, base []Float)
 }
 
 func PeaksToPairs(peaks []Peak) (pairs []PeakPair) {
+	return defaultFingerprinter.PeaksToPairs(peaks)
+}
+
+func (fp *Fingerprinter) PeaksToPairs(peaks []Peak) (pairs []PeakPair) {
 	if len(peaks) == 0 {
 		return
 	}
 
-	timeCnt := peaks[len(peaks)-1].Time + 1
+	// пики обычно отсортированы по времени, но не полагаемся на это
+	timeCnt := uint(0)
+	for _, peak := range peaks {
+		if peak.Time >= timeCnt {
+			timeCnt = peak.Time + 1
+		}
+	}
 
 	peaksAt := make([][]uint, timeCnt)
 	for _, peak := range peaks {
@@ -493,14 +526,14 @@ func PeaksToPairs(peaks []Peak) (pairs []PeakPair) {
 	pairsLoop:
 		for _, bin1 := range peaksAt[time1] {
 			pairsFromThisPeak := 0
-			lastTime2 := minUint(timeCnt, time1+lookaheadTimeDiffMax)
-			for time2 := time1 + lookaheadTimeDiffMin; time2 < lastTime2; time2++ {
+			lastTime2 := minUint(timeCnt, time1+uint(fp.cfg.LookaheadTimeDiffMax))
+			for time2 := time1 + uint(fp.cfg.LookaheadTimeDiffMin); time2 < lastTime2; time2++ {
 				for _, bin2 := range peaksAt[time2] {
-					if absInt(int(bin2)-int(bin1)) < lookaheadBinDiffMax {
+					if absInt(int(bin2)-int(bin1)) < fp.cfg.LookaheadBinDiffMax {
 						pair := NewPeakPair(time1, bin1, time2, bin2)
 						pairs = append(pairs, pair)
 
-						if pairsFromThisPeak++; pairsFromThisPeak >= maxPairsPerPeak {
+						if pairsFromThisPeak++; pairsFromThisPeak >= fp.cfg.MaxPairsPerPeak {
 							continue pairsLoop
 						}
 					}
@@ -513,15 +546,9 @@ func PeaksToPairs(peaks []Peak) (pairs []PeakPair) {
 }
 
 func PeakPairsToHashes(pairs []PeakPair) (hashes Hashes) {
-	hashes = make([]Hash, len(pairs))
-
-	for i, pair := range pairs {
-		hashes[i] = Hash{Time: uint32(pair.Time1), Hash: pair.ToHash()}
-	}
-
-	return
+	return defaultFingerprinter.PeakPairsToHashes(pairs)
 }
 
 func FindHashes(peaks []Peak) (hashes Hashes) {
-	return PeakPairsToHashes(PeaksToPairs(peaks))
+	return defaultFingerprinter.FindHashes(peaks)
 }
@@ -0,0 +1,372 @@
+package fennec
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/mjibson/go-dsp/fft"
+)
+
+const (
+	// сколько секунд звука копится до начала выдачи хешей, чтобы оценить среднее для нормировки спектрограммы
+	streamWarmupSec = 5
+	// до какой доли затухает огибающая пика за время look-behind обратного прохода (см. DefaultStreamLookbehind)
+	streamPruneDecay = 0.2
+	// обратный проход запускается, когда набирается столько колонок сверх look-behind (в долях look-behind)
+	streamPruneStepK = 0.25
+	// сколько колонок нужно для начального порога прямого прохода (см. scanForPeaks)
+	streamScanInitCols = 10
+)
+
+type (
+	// StreamFingerprinter строит хеши по бесконечному потоку PCM, выдавая их порциями с ограниченной задержкой.
+	//
+	// Отличия от пакетного пути (Fingerprinter.Hashes) на том же звуке:
+	//   - спектрограмма нормируется не по среднему всего трека, а по среднему первых streamWarmupSec секунд,
+	//     которое затем уточняется по всем последующим колонкам (на треках короче streamWarmupSec совпадает точно);
+	//   - обратный проход отсева пиков (filterPeaks) видит только lookbehind колонок вперед, а не весь трек.
+	//     Более дальние пики затухают сильнее чем до streamPruneDecay и почти никогда не влияют на результат.
+	// Поэтому хеши совпадают с пакетными не полностью, но на практике почти все.
+	//
+	// Время хешей отсчитывается от начала потока. Не безопасен для конкурентного использования.
+	StreamFingerprinter struct {
+		fp *Fingerprinter
+
+		stride     int
+		lookbehind int
+		pruneStep  int
+		warmup     int
+
+		gaus Gaussian
+		win  []float64
+
+		// еще не разобранные на окна FFT семплы (pcm[0] - начало очередного окна)
+		pcm   []Float
+		total int64
+
+		// нормировка: максимум сырых амплитуд и сумма логарифмов по всем колонкам
+		rawMax  Float
+		logSum  float64
+		logCnt  int64
+		pending [][]Float
+
+		// нормированные колонки и пики прямого прохода, начиная с колонки colsBase
+		cols     [][]Float
+		scanned  []PeakSpectrSlice
+		colsBase int
+		colsCnt  int
+		thresh   []Float
+
+		// колонки < done окончательно отфильтрованы, их пики лежат в peaksAt начиная с колонки peaksBase
+		done      int
+		peaksAt   [][]uint
+		peaksBase int
+		// для колонок < paired пары пиков уже выданы
+		paired int
+
+		flushed bool
+	}
+)
+
+// DefaultStreamLookbehind look-behind обратного прохода по умолчанию: за это время огибающая пика затухает до streamPruneDecay
+func (fp *Fingerprinter) DefaultStreamLookbehind() time.Duration {
+	cols := math.Ceil(math.Log(streamPruneDecay) / math.Log(fp.cfg.DecayingKoeff))
+	return time.Duration(cols / fp.cfg.HashColsInOneSec() * float64(time.Second))
+}
+
+// NewStream создает потоковый построитель хешей с look-behind по умолчанию
+func (fp *Fingerprinter) NewStream() *StreamFingerprinter {
+	return fp.NewStreamWithLookbehind(fp.DefaultStreamLookbehind())
+}
+
+// NewStreamWithLookbehind создает потоковый построитель хешей. Задержка выдачи хешей не превышает
+// lookbehind*(1+streamPruneStepK) плюс LookaheadTimeDiffMax колонок: больше lookbehind - ближе к пакетному результату.
+func (fp *Fingerprinter) NewStreamWithLookbehind(lookbehind time.Duration) *StreamFingerprinter {
+	colsInOneSec := fp.cfg.HashColsInOneSec()
+
+	s := &StreamFingerprinter{
+		fp:         fp,
+		stride:     fp.cfg.FFTWinSize - fp.cfg.FFTOverlap,
+		lookbehind: maxInt(1, int(math.Ceil(lookbehind.Seconds()*colsInOneSec))),
+		warmup:     maxInt(streamScanInitCols, int(math.Ceil(streamWarmupSec*colsInOneSec))),
+		win:        make([]float64, fp.cfg.FFTWinSize),
+	}
+	s.pruneStep = maxInt(1, int(float64(s.lookbehind)*streamPruneStepK))
+
+	return s
+}
+
+// Version версия параметров, с которыми строятся хеши
+func (s *StreamFingerprinter) Version() FingerprintVersion {
+	return s.fp.version
+}
+
+// Duration сколько звука уже поступило в поток
+func (s *StreamFingerprinter) Duration() time.Duration {
+	return time.Duration(float64(s.total) / float64(s.fp.cfg.SampleRate) * float64(time.Second))
+}
+
+// Push добавляет очередную порцию моно PCM с частотой Config().SampleRate и возвращает хеши, ставшие окончательными
+func (s *StreamFingerprinter) Push(pcm []Float) (Hashes, error) {
+	if s.flushed {
+		return nil, ErrWrongParams
+	}
+
+	s.pcm = append(s.pcm, pcm...)
+	s.total += int64(len(pcm))
+
+	winSize := s.fp.cfg.FFTWinSize
+	offs := 0
+	for ; offs+winSize <= len(s.pcm); offs += s.stride {
+		s.addColumn(s.pcm[offs : offs+winSize])
+	}
+	s.pcm = append(s.pcm[0:0], s.pcm[offs:]...)
+
+	s.scan()
+
+	if s.scannedCnt()-s.done >= s.lookbehind+s.pruneStep {
+		s.prune(s.scannedCnt()-s.lookbehind, false)
+	}
+
+	return s.pairs(false), nil
+}
+
+// Flush завершает поток (как конец трека в пакетном пути) и возвращает оставшиеся хеши.
+// Если за весь поток не набралось ни одного окна FFT, возвращается ErrTooShort, если звука не было - ErrSilentInput.
+func (s *StreamFingerprinter) Flush() (Hashes, error) {
+	if s.flushed {
+		return nil, ErrWrongParams
+	}
+	s.flushed = true
+
+	if s.total < int64(s.fp.cfg.FFTWinSize) {
+		return nil, ErrTooShort
+	}
+
+	// хвост дополняется нулями, как последние окна в buildSpectre
+	winSize := s.fp.cfg.FFTWinSize
+	for offs := 0; offs < len(s.pcm); offs += s.stride {
+		s.addColumn(s.pcm[offs:minInt(len(s.pcm), offs+winSize)])
+	}
+	s.pcm = nil
+
+	if s.rawMax < 1e-6 {
+		return nil, ErrSilentInput
+	}
+
+	if s.pending != nil {
+		s.normalizePending()
+	}
+	s.scan()
+	s.prune(s.colsCnt, true)
+
+	return s.pairs(true), nil
+}
+
+// addColumn считает очередную колонку спектрограммы по окну wave (короче FFTWinSize - дополняется нулями)
+func (s *StreamFingerprinter) addColumn(wave []Float) {
+	halfWinSize := s.fp.cfg.FFTWinSize / 2
+
+	for i := range s.win {
+		s.win[i] = 0
+	}
+	for i, v := range wave {
+		s.win[i] = float64(v) * s.fp.winFunc[i]
+	}
+
+	line := fft.FFTReal(s.win)
+	mags := make([]Float, halfWinSize+1)
+	for i := range mags {
+		mags[i] = Float(cmplx.Abs(line[i]))
+		s.rawMax = maxFloat(s.rawMax, mags[i])
+	}
+
+	if s.colsCnt+len(s.pending) < s.warmup {
+		s.pending = append(s.pending, mags)
+		return
+	}
+
+	if s.pending != nil {
+		s.normalizePending()
+	}
+	s.normalize(mags)
+	s.appendColumn(mags)
+}
+
+// logMags переводит амплитуды в логарифмы (как в buildSpectre) и учитывает их в среднем
+func (s *StreamFingerprinter) logMags(mags []Float) {
+	// buildSpectre ограничивает амплитуды снизу долей максимума по всему файлу, а здесь известен только
+	// максимум до текущей колонки. Пока он растет, тихие колонки ограничиваются ниже, чем в пакетном
+	// режиме, и среднее логарифмов (а с ним и нормированные колонки) немного расходится с buildSpectre.
+	minMag := s.rawMax / 1e6
+	if minMag <= 0 {
+		minMag = math.SmallestNonzeroFloat32
+	}
+
+	for i, mag := range mags {
+		l := math.Log(float64(maxFloat(mag, minMag)))
+		mags[i] = Float(l)
+		s.logSum += l
+		s.logCnt++
+	}
+}
+
+// normalize нормирует колонку по текущему среднему. Последняя строка (частота Найквиста) отбрасывается, как в buildSpectre.
+func (s *StreamFingerprinter) normalize(mags []Float) {
+	s.logMags(mags)
+
+	mean := Float(s.logSum / float64(s.logCnt))
+	for i := range mags {
+		mags[i] -= mean
+	}
+}
+
+// normalizePending нормирует колонки, накопленные за время разогрева, по их общему среднему
+func (s *StreamFingerprinter) normalizePending() {
+	for _, mags := range s.pending {
+		s.logMags(mags)
+	}
+
+	mean := Float(s.logSum / float64(s.logCnt))
+	for _, mags := range s.pending {
+		for i := range mags {
+			mags[i] -= mean
+		}
+		s.appendColumn(mags)
+	}
+
+	s.pending = nil
+}
+
+func (s *StreamFingerprinter) appendColumn(mags []Float) {
+	s.cols = append(s.cols, mags[:len(mags)-1])
+	s.colsCnt++
+}
+
+func (s *StreamFingerprinter) scannedCnt() int {
+	return s.colsBase + len(s.scanned)
+}
+
+// scan прямой проход (scanForPeaks) по еще не просмотренным колонкам
+func (s *StreamFingerprinter) scan() {
+	if s.thresh == nil {
+		// начальный порог по первым колонкам потока. До конца потока их может быть меньше streamScanInitCols.
+		initCols := minInt(streamScanInitCols, len(s.cols))
+		if (initCols == 0) || ((initCols < streamScanInitCols) && !s.flushed) {
+			return
+		}
+
+		maxs := make([]Float, len(s.cols[0]))
+		for _, col := range s.cols[:initCols] {
+			maximumFloat(maxs, col)
+		}
+		s.thresh = spreadPeaksInVector(maxs, s.fp.cfg.GaussianWidth, &s.gaus)
+	}
+
+	for i := len(s.scanned); i < len(s.cols); i++ {
+		var peaks PeakSpectrSlice
+		s.thresh, peaks = s.fp.scanColumn(s.cols[i], s.thresh, &s.gaus)
+		s.scanned = append(s.scanned, peaks)
+	}
+}
+
+// prune обратный проход (filterPeaks) по колонкам [done-1, scannedCnt), после которого колонки до finalTo
+// считаются окончательными. Проход начинается с последней просмотренной колонки, как с конца трека в пакетном пути.
+func (s *StreamFingerprinter) prune(finalTo int, final bool) {
+	end := s.scannedCnt()
+	if (end == 0) || (finalTo <= s.done) {
+		return
+	}
+
+	from := maxInt(0, s.done-1)
+	rows := len(s.cols[0])
+
+	// kept[c-from][bin]: пик прямого прохода пережил обратный проход
+	kept := make([][]bool, end-from)
+	for c := from; c < end; c++ {
+		kept[c-from] = make([]bool, rows)
+		for _, peak := range s.scanned[c-s.colsBase] {
+			kept[c-from][peak.Idx] = true
+		}
+	}
+
+	thresh := spreadPeaksInVector(s.cols[end-1-s.colsBase], s.fp.cfg.GaussianWidth, &s.gaus)
+
+	for col := end; col > from; col-- {
+		colPeaks := append(PeakSpectrSlice(nil), s.scanned[col-1-s.colsBase]...)
+
+		var passed []bool
+		thresh, passed = s.fp.filterColumn(colPeaks, thresh, &s.gaus)
+
+		for i, peak := range colPeaks {
+			if passed[i] {
+				if col < end {
+					kept[col-from][peak.Idx] = false
+				}
+			} else {
+				kept[col-1-from][peak.Idx] = false
+			}
+		}
+	}
+
+	if final {
+		finalTo = end
+	}
+
+	for c := s.done; c < finalTo; c++ {
+		var bins []uint
+		for bin, ok := range kept[c-from] {
+			if ok {
+				bins = append(bins, uint(bin))
+			}
+		}
+		s.peaksAt = append(s.peaksAt, bins)
+	}
+	s.done = finalTo
+
+	// для следующего прохода нужна только колонка done-1 и дальше
+	if drop := s.done - 1 - s.colsBase; drop > 0 {
+		s.cols = append(s.cols[0:0], s.cols[drop:]...)
+		s.scanned = append(s.scanned[0:0], s.scanned[drop:]...)
+		s.colsBase += drop
+	}
+}
+
+// pairs выдает хеши для колонок, все пары пиков которых уже окончательны (см. PeaksToPairs)
+func (s *StreamFingerprinter) pairs(final bool) Hashes {
+	cfg := &s.fp.cfg
+
+	var pairs []PeakPair
+	for ; s.paired < s.done; s.paired++ {
+		time1 := uint(s.paired)
+		if !final && (s.paired+cfg.LookaheadTimeDiffMax > s.done) {
+			break
+		}
+
+		lastTime2 := minUint(uint(s.done), time1+uint(cfg.LookaheadTimeDiffMax))
+
+	pairsLoop:
+		for _, bin1 := range s.peaksAt[s.paired-s.peaksBase] {
+			pairsFromThisPeak := 0
+			for time2 := time1 + uint(cfg.LookaheadTimeDiffMin); time2 < lastTime2; time2++ {
+				for _, bin2 := range s.peaksAt[int(time2)-s.peaksBase] {
+					if absInt(int(bin2)-int(bin1)) < cfg.LookaheadBinDiffMax {
+						pairs = append(pairs, NewPeakPair(time1, bin1, time2, bin2))
+
+						if pairsFromThisPeak++; pairsFromThisPeak >= cfg.MaxPairsPerPeak {
+							continue pairsLoop
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if drop := s.paired - s.peaksBase; drop > 0 {
+		s.peaksAt = append(s.peaksAt[0:0], s.peaksAt[drop:]...)
+		s.peaksBase += drop
+	}
+
+	return s.fp.PeakPairsToHashes(pairs)
+}
@@ -0,0 +1,59 @@
+package fennec
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestStreamHashes проверяет, что хеши, выданные потоком порциями разной длины, почти совпадают с пакетными.
+// Полного совпадения нет из-за нормировки по текущему среднему и ограниченного look-behind (см. StreamFingerprinter).
+func TestStreamHashes(t *testing.T) {
+	pcm := testMusic(60, 1, 1)
+	batch := testHashes(t, pcm)
+
+	r := rand.New(rand.NewSource(1))
+	stream := DefaultFingerprinter().NewStream()
+
+	var hashes Hashes
+	for pos := 0; pos < len(pcm); {
+		chunk := minInt(len(pcm)-pos, 1+r.Intn(8000))
+		part, err := stream.Push(pcm[pos : pos+chunk])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, part...)
+		pos += chunk
+	}
+	part, err := stream.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes = append(hashes, part...)
+
+	for i := 1; i < len(hashes); i++ {
+		if hashes[i].Time < hashes[i-1].Time {
+			t.Fatalf("hash %d: time %d after %d", i, hashes[i].Time, hashes[i-1].Time)
+		}
+	}
+
+	inBatch := make(map[Hash]bool, len(batch))
+	for _, h := range batch {
+		inBatch[h] = true
+	}
+	common := 0
+	for _, h := range hashes {
+		if inBatch[h] {
+			common++
+		}
+	}
+
+	res := NewMatcher().Match(hashes, batch)
+	t.Logf("batch %d, stream %d, common %d: %s", len(batch), len(hashes), common, res)
+
+	if (float64(common) < 0.95*float64(len(batch))) || (float64(common) < 0.95*float64(len(hashes))) {
+		t.Errorf("only %d common hashes of %d in batch and %d in stream", common, len(batch), len(hashes))
+	}
+	if (res.Similarity < 0.9) || (res.OffsetInSec != 0) || (res.Scale != 1) {
+		t.Errorf("stream does not match batch: %s", res)
+	}
+}
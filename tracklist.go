@@ -0,0 +1,86 @@
+package fennec
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// TracklistEntry один трек каталога в разметке длинной записи
+	TracklistEntry struct {
+		TrackID TrackID
+		// интервал записи, в котором играл трек (при сведении соседние интервалы перекрываются)
+		Start, End time.Duration
+		// позиция в треке, соответствующая Start
+		TrackOffset time.Duration
+		// средняя похожесть (0..1) по совпавшим окнам
+		Confidence float64
+	}
+)
+
+var (
+	// DefaultTracklistConfig параметры окон для разметки миксов: окна длиннее, чем в эфирном мониторинге,
+	// а порог ниже, т.к. треки в миксе звучат поверх друг друга и с эффектами
+	DefaultTracklistConfig = MonitorConfig{
+		Window:        15 * time.Second,
+		Step:          3 * time.Second,
+		TopK:          5,
+		MinSimilarity: 0.2,
+		StartHits:     2,
+		StopMisses:    3,
+	}
+)
+
+// Tracklist размечает длинную запись (DJ микс, эфир) по трекам каталога: скользящее окно записи ищется в каталоге,
+// а совпадения сглаживаются между окнами так же, как в Monitor. mix - хеши всей записи, построенные fp.
+// Записи возвращаются по возрастанию Start.
+func Tracklist(catalog Catalog, fp *Fingerprinter, mix Hashes, cfg MonitorConfig) ([]TracklistEntry, error) {
+	if err := checkVersion(catalog.Version(), fp.Version()); err != nil {
+		return nil, err
+	}
+
+	mon, err := newMonitor(catalog, fp.cfg.HashColsInOneSec(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	byTime := append(Hashes(nil), mix...)
+	sort.Sort(hashesByTimeHash(byTime))
+
+	completeTo := 0
+	if l := len(byTime); l > 0 {
+		completeTo = int(byTime[l-1].Time) + 1
+	}
+
+	events := mon.PushHashes(byTime, completeTo)
+	events = append(events, mon.FlushHashes()...)
+
+	// событие конца содержит весь интервал воспроизведения
+	var entries []TracklistEntry
+	for _, ev := range events {
+		if ev.Type != MonitorStop {
+			continue
+		}
+		entries = append(entries, TracklistEntry{
+			TrackID:     ev.TrackID,
+			Start:       ev.StreamStart,
+			End:         ev.StreamEnd,
+			TrackOffset: ev.TrackOffset,
+			Confidence:  ev.Confidence,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+
+	return entries, nil
+}
+
+// TracklistFromFile размечает аудио файл любого поддерживаемого формата (см. Tracklist)
+func TracklistFromFile(catalog Catalog, fp *Fingerprinter, path string, cfg MonitorConfig) ([]TracklistEntry, error) {
+	hashes, err := fp.HashesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Tracklist(catalog, fp, hashes, cfg)
+}
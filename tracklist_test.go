@@ -0,0 +1,54 @@
+package fennec
+
+import (
+	"math"
+	"testing"
+)
+
+// TestTracklist размечает запись из фрагментов двух треков каталога, идущих подряд
+func TestTracklist(t *testing.T) {
+	trackA, trackB := testMusic(60, 1, 1), testMusic(60, 1, 2)
+
+	idx := NewIndex()
+	idx.Add(1, testHashes(t, trackA))
+	idx.Add(2, testHashes(t, trackB))
+	idx.Add(3, testHashes(t, testMusic(60, 1, 3)))
+
+	// A[10:50), затем B[5:45)
+	var mix []Float
+	mix = append(mix, trackA[10*SampleRate:50*SampleRate]...)
+	mix = append(mix, trackB[5*SampleRate:45*SampleRate]...)
+
+	entries, err := Tracklist(idx, DefaultFingerprinter(), testHashes(t, mix), DefaultTracklistConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		id                      TrackID
+		start, end, trackOffset float64
+	}{
+		{1, 0, 40, 10},
+		{2, 40, 80, 5},
+	}
+
+	for _, e := range entries {
+		t.Logf("%d: %s-%s track %s confidence %.3f", e.TrackID, e.Start, e.End, e.TrackOffset, e.Confidence)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("%d entries, expected %d", len(entries), len(expected))
+	}
+
+	// границы определяются с точностью до шага окна
+	tolerance := DefaultTracklistConfig.Step.Seconds()
+	for i, e := range entries {
+		exp := expected[i]
+		if e.TrackID != exp.id {
+			t.Errorf("entry %d: track %d, expected %d", i, e.TrackID, exp.id)
+		}
+		if (math.Abs(e.Start.Seconds()-exp.start) > tolerance) || (math.Abs(e.End.Seconds()-exp.end) > tolerance) ||
+			(math.Abs(e.TrackOffset.Seconds()-exp.trackOffset) > tolerance) {
+			t.Errorf("entry %d: %s-%s track %s, expected %.0fs-%.0fs track %.0fs", i, e.Start, e.End, e.TrackOffset, exp.start, exp.end, exp.trackOffset)
+		}
+	}
+}
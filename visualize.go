@@ -4,6 +4,7 @@ import (
 	"github.com/llgcode/draw2d/draw2dimg"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"math"
 	"os"
@@ -111,3 +112,53 @@ func SaveToPng(img image.Image, path string) error {
 		return nil
 	}
 }
+
+// VisualizeMatrix рисует тепловую карту квадратной матрицы похожести (значения 0..1, см. SimilarityMatrix):
+// клетка cellSize x cellSize пикселей на пару треков, от черного (0) через красный и желтый к белому (1).
+// Клетки разделены линией фона, если cellSize >= 4.
+func VisualizeMatrix(similarity [][]float64, cellSize int) image.Image {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+
+	n := len(similarity)
+	img := image.NewRGBA(image.Rect(0, 0, n*cellSize, n*cellSize))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{40, 40, 40, 255}}, image.Point{}, draw.Src)
+
+	border := 0
+	if cellSize >= 4 {
+		border = 1
+	}
+
+	for row := 0; row < n; row++ {
+		for col := 0; col < n; col++ {
+			var v float64
+			if col < len(similarity[row]) {
+				v = similarity[row][col]
+			}
+			pix := heatColor(v)
+
+			for y := row*cellSize + border; y < (row+1)*cellSize; y++ {
+				for x := col*cellSize + border; x < (col+1)*cellSize; x++ {
+					img.Set(x, y, pix)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// heatColor цвет значения 0..1 на шкале черный - красный - желтый - белый
+func heatColor(v float64) color.RGBA {
+	v = math.Max(0, math.Min(1, v)) * 3
+
+	switch {
+	case v < 1:
+		return color.RGBA{uint8(255 * v), 0, 0, 255}
+	case v < 2:
+		return color.RGBA{255, uint8(255 * (v - 1)), 0, 255}
+	default:
+		return color.RGBA{255, 255, uint8(255 * (v - 2)), 255}
+	}
+}
@@ -0,0 +1,124 @@
+package fennec
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+
+	// fmt чанк в реальности не длиннее 40 байт, ограничение защищает от огромных аллокаций на битых файлах
+	wavMaxFmtSize = 1024
+)
+
+// NewWAVReader создает покадровый читатель WAV файла (PCM 8/16/24/32 бит и float)
+func NewWAVReader(path string, sampleRate int, bits int) (*rawPCMReader, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := NewWAVReaderFrom(fd, sampleRate, bits)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	rd.closer = fd
+
+	return rd, nil
+}
+
+// NewWAVReaderFrom создает покадровый читатель WAV, читаемого из r
+func NewWAVReaderFrom(r io.Reader, sampleRate int, bits int) (*rawPCMReader, error) {
+	format, data, err := readWavHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRawPCMReader(data, format, sampleRate, bits)
+}
+
+// readWavHeader разбирает RIFF заголовок до начала чанка data
+// и возвращает формат семплов и reader, ограниченный этим чанком
+func readWavHeader(r io.Reader) (format RawPCMFormat, data io.Reader, err error) {
+	var riff [12]byte
+	if _, err = io.ReadFull(r, riff[:]); err != nil {
+		return format, nil, ErrUnsupportedFormat
+	} else if string(riff[0:4]) != `RIFF` || string(riff[8:12]) != `WAVE` {
+		return format, nil, ErrUnsupportedFormat
+	}
+
+	// конец RIFF чанка и текущая позиция от начала файла
+	riffSize := binary.LittleEndian.Uint32(riff[4:8])
+	riffEnd, pos := 8+int64(riffSize), int64(len(riff))
+
+	fmtFound := false
+	for {
+		var chunk [8]byte
+		if _, err = io.ReadFull(r, chunk[:]); err != nil {
+			return format, nil, ErrUnsupportedFormat
+		}
+
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		pos += int64(len(chunk)) + size + size%2
+
+		switch id {
+		case `fmt `:
+			if (size < 16) || (size > wavMaxFmtSize) {
+				return format, nil, ErrUnsupportedFormat
+			}
+
+			body := make([]byte, size+size%2)
+			if _, err = io.ReadFull(r, body); err != nil {
+				return format, nil, ErrUnsupportedFormat
+			}
+
+			audioFormat := binary.LittleEndian.Uint16(body[0:2])
+			if (audioFormat == wavFormatExtensible) && (size >= 26) {
+				// первые 2 байта SubFormat GUID совпадают с кодом формата
+				audioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+
+			format.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			format.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+
+			switch audioFormat {
+			case wavFormatPCM:
+			case wavFormatFloat:
+				format.Float = true
+			default:
+				return format, nil, ErrUnsupportedFormat
+			}
+
+			if err = format.validate(); err != nil {
+				return format, nil, err
+			}
+
+			fmtFound = true
+
+		case `data`:
+			if !fmtFound {
+				return format, nil, ErrUnsupportedFormat
+			}
+
+			// WAV, записываемый потоком: размер неизвестен, читаем до конца.
+			// Нулевой размер так понимается, только если по заголовку RIFF этот чанк последний (или размер RIFF тоже не записан),
+			//   иначе это просто пустой чанк.
+			if (size == 0xFFFFFFFF) || ((size == 0) && ((riffSize == 0) || (riffSize == 0xFFFFFFFF) || (pos >= riffEnd))) {
+				return format, r, nil
+			}
+			return format, io.LimitReader(r, size), nil
+
+		default:
+			if _, err = io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return format, nil, ErrUnsupportedFormat
+			}
+		}
+	}
+}
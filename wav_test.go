@@ -0,0 +1,196 @@
+package fennec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+const (
+	// частота тестовых WAV совпадает с частотой чтения, чтобы семплы не передискретизировались
+	testWavSampleRate = 8000
+)
+
+var (
+	// кратны 256, так что без потерь представимы и в 8 битах
+	testWavSamples = []int16{0, 256, -256, 32512, -32512, 12800, -7680}
+)
+
+// testWavData кодирует семплы (одинаковые во всех каналах) в interleaved PCM формата format
+func testWavData(format RawPCMFormat, samples []int16) []byte {
+	var buf bytes.Buffer
+	for _, v := range samples {
+		for ch := 0; ch < format.Channels; ch++ {
+			switch {
+			case format.Float && (format.BitsPerSample == 32):
+				binary.Write(&buf, binary.LittleEndian, float32(v)/float32(int16ToFloat-1))
+			case format.Float:
+				binary.Write(&buf, binary.LittleEndian, float64(v)/float64(int16ToFloat-1))
+			case format.BitsPerSample == 8:
+				buf.WriteByte(byte(int(v)>>8 + 128))
+			case format.BitsPerSample == 16:
+				binary.Write(&buf, binary.LittleEndian, v)
+			case format.BitsPerSample == 24:
+				buf.Write([]byte{0x55, byte(v), byte(v >> 8)})
+			default:
+				binary.Write(&buf, binary.LittleEndian, int32(v)<<16|0x5555)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// testWav собирает WAV с fmt (обычным или WAVE_FORMAT_EXTENSIBLE) и data; размеры RIFF и data можно подменить
+func testWav(format RawPCMFormat, extensible bool, data []byte, riffSize, dataSize uint32) []byte {
+	le := binary.LittleEndian
+
+	code := uint16(wavFormatPCM)
+	if format.Float {
+		code = wavFormatFloat
+	}
+	blockAlign := uint16(format.Channels * format.bytesPerSample())
+
+	var fmtBody bytes.Buffer
+	binary.Write(&fmtBody, le, code)
+	if extensible {
+		fmtBody.Reset()
+		binary.Write(&fmtBody, le, uint16(wavFormatExtensible))
+	}
+	binary.Write(&fmtBody, le, uint16(format.Channels))
+	binary.Write(&fmtBody, le, uint32(format.SampleRate))
+	binary.Write(&fmtBody, le, uint32(format.SampleRate)*uint32(blockAlign))
+	binary.Write(&fmtBody, le, blockAlign)
+	binary.Write(&fmtBody, le, uint16(format.BitsPerSample))
+	if extensible {
+		binary.Write(&fmtBody, le, uint16(22))
+		binary.Write(&fmtBody, le, uint16(format.BitsPerSample))
+		binary.Write(&fmtBody, le, uint32(3))
+		// SubFormat GUID: код формата и хвост KSDATAFORMAT_SUBTYPE
+		binary.Write(&fmtBody, le, code)
+		fmtBody.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71})
+	}
+
+	var wav bytes.Buffer
+	wav.WriteString(`RIFF`)
+	binary.Write(&wav, le, riffSize)
+	wav.WriteString(`WAVEfmt `)
+	binary.Write(&wav, le, uint32(fmtBody.Len()))
+	wav.Write(fmtBody.Bytes())
+	wav.WriteString(`data`)
+	binary.Write(&wav, le, dataSize)
+	wav.Write(data)
+
+	return wav.Bytes()
+}
+
+// testWavSized собирает WAV с правильными размерами RIFF и data
+func testWavSized(format RawPCMFormat, extensible bool, data []byte) []byte {
+	wav := testWav(format, extensible, data, 0, uint32(len(data)))
+	binary.LittleEndian.PutUint32(wav[4:8], uint32(len(wav)-8))
+	return wav
+}
+
+// testReadWav читает WAV без передискретизации и возвращает int16 семплы
+func testReadWav(t *testing.T, wav []byte) []int16 {
+	t.Helper()
+
+	rd, err := NewWAVReaderFrom(bytes.NewReader(wav), testWavSampleRate, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var samples, buf []int16
+	for {
+		if buf, err = rd.ReadFrame(buf); err == io.EOF {
+			return samples
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, buf...)
+	}
+}
+
+func TestWavFormats(t *testing.T) {
+	cases := []struct {
+		name       string
+		format     RawPCMFormat
+		extensible bool
+	}{
+		{`pcm8`, RawPCMFormat{Channels: 1, BitsPerSample: 8}, false},
+		{`pcm16`, RawPCMFormat{Channels: 1, BitsPerSample: 16}, false},
+		{`pcm16 stereo`, RawPCMFormat{Channels: 2, BitsPerSample: 16}, false},
+		{`pcm24`, RawPCMFormat{Channels: 1, BitsPerSample: 24}, false},
+		{`pcm32`, RawPCMFormat{Channels: 1, BitsPerSample: 32}, false},
+		{`float32`, RawPCMFormat{Channels: 1, BitsPerSample: 32, Float: true}, false},
+		{`float64`, RawPCMFormat{Channels: 1, BitsPerSample: 64, Float: true}, false},
+		{`extensible pcm24 stereo`, RawPCMFormat{Channels: 2, BitsPerSample: 24}, true},
+		{`extensible float32`, RawPCMFormat{Channels: 1, BitsPerSample: 32, Float: true}, true},
+	}
+
+	for _, c := range cases {
+		c.format.SampleRate = testWavSampleRate
+		samples := testReadWav(t, testWavSized(c.format, c.extensible, testWavData(c.format, testWavSamples)))
+
+		if len(samples) != len(testWavSamples) {
+			t.Errorf("%s: decoded %d samples, expected %d", c.name, len(samples), len(testWavSamples))
+			continue
+		}
+		for i, v := range samples {
+			if v != testWavSamples[i] {
+				t.Errorf("%s: sample %d is %d, expected %d", c.name, i, v, testWavSamples[i])
+			}
+		}
+	}
+}
+
+func TestWavFloatClamp(t *testing.T) {
+	format := RawPCMFormat{SampleRate: testWavSampleRate, Channels: 1, BitsPerSample: 32, Float: true}
+
+	var data bytes.Buffer
+	for _, v := range []float32{float32(math.NaN()), 2, -2, float32(math.Inf(1))} {
+		binary.Write(&data, binary.LittleEndian, v)
+	}
+
+	expected := []int16{0, 32767, -32767, 32767}
+	samples := testReadWav(t, testWavSized(format, false, data.Bytes()))
+	if len(samples) != len(expected) {
+		t.Fatalf("decoded %d samples, expected %d", len(samples), len(expected))
+	}
+	for i, v := range samples {
+		if v != expected[i] {
+			t.Errorf("sample %d is %d, expected %d", i, v, expected[i])
+		}
+	}
+}
+
+func TestWavDataSize(t *testing.T) {
+	format := RawPCMFormat{SampleRate: testWavSampleRate, Channels: 1, BitsPerSample: 16}
+	data := testWavData(format, testWavSamples)
+	all := len(testWavSamples)
+
+	// пустой data, за которым по заголовку RIFF идет еще чанк
+	list := append([]byte("LIST\x04\x00\x00\x00INFO"), data...)
+	notLast := testWav(format, false, list, 0, 0)
+	binary.LittleEndian.PutUint32(notLast[4:8], uint32(len(notLast)-8))
+
+	cases := []struct {
+		name     string
+		wav      []byte
+		expected int
+	}{
+		{`sized`, testWavSized(format, false, data), all},
+		{`declared size shorter than file`, testWav(format, false, data, 0xFFFFFFFF, 4), 2},
+		{`streamed, sizes not written`, testWav(format, false, data, 0, 0), all},
+		{`streamed, unknown sizes`, testWav(format, false, data, 0xFFFFFFFF, 0xFFFFFFFF), all},
+		{`zero data size, last chunk by RIFF`, testWav(format, false, data, 36, 0), all},
+		{`zero data size, not last chunk`, notLast, 0},
+	}
+
+	for _, c := range cases {
+		if samples := testReadWav(t, c.wav); len(samples) != c.expected {
+			t.Errorf("%s: decoded %d samples, expected %d", c.name, len(samples), c.expected)
+		}
+	}
+}